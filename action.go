@@ -0,0 +1,74 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import "strconv"
+
+// An Action tells how to proceed after an ActionHandler handled an error.
+type Action int
+
+const (
+	// Next passes the returned error to the next handler, as a Handler
+	// does. As with a Handler, returning a nil error discards the error.
+	Next Action = iota
+
+	// Continue skips the remaining handlers and resumes execution as if the
+	// error did not occur. A non-nil returned error is recorded as a soft
+	// error, as with Check.
+	Continue
+
+	// Retry skips the remaining handlers and calls the failed operation
+	// again. Only errors from functions passed to Do or Defer can be retried.
+	// For other errors, Retry is treated as Abort.
+	Retry
+
+	// Replace is like Abort, but the returned error replaces any error that
+	// was recorded before, regardless of the Selector of the Runner.
+	Replace
+
+	// Abort skips the remaining handlers and causes Run to return, even if
+	// the error was passed to Check. The returned error, or the error passed
+	// to the handler if it is nil, is recorded as the error.
+	Abort
+)
+
+func (a Action) String() string {
+	switch a {
+	case Next:
+		return "Next"
+	case Continue:
+		return "Continue"
+	case Retry:
+		return "Retry"
+	case Replace:
+		return "Replace"
+	case Abort:
+		return "Abort"
+	}
+	return "Action(" + strconv.Itoa(int(a)) + ")"
+}
+
+// An ActionHandler is a Handler that can also determine how to proceed after
+// handling an error. When applying handlers, package errd calls HandleAction
+// instead of Handle for handlers that implement ActionHandler.
+type ActionHandler interface {
+	Handler
+	HandleAction(s State, err error) (Action, error)
+}
+
+// The ActionFunc type is an adapter to allow the use of ordinary functions as
+// ActionHandlers.
+type ActionFunc func(s State, err error) (Action, error)
+
+// Handle calls f(s, err) and returns the error.
+func (f ActionFunc) Handle(s State, err error) error {
+	_, err = f(s, err)
+	return err
+}
+
+// HandleAction calls f(s, err).
+func (f ActionFunc) HandleAction(s State, err error) (Action, error) {
+	return f(s, err)
+}
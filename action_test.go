@@ -0,0 +1,186 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func act(a Action, err error) Handler {
+	return ActionFunc(func(s State, e error) (Action, error) { return a, err })
+}
+
+// retry returns a Handler that requests a retry until the operation was
+// called n times in total.
+func retry(n int, calls *int) Handler {
+	return ActionFunc(func(s State, err error) (Action, error) {
+		if *calls < n {
+			return Retry, nil
+		}
+		return Next, err
+	})
+}
+
+func TestActions(t *testing.T) {
+	var calls int
+	op := func(err error) func() error {
+		return func() error {
+			calls++
+			return err
+		}
+	}
+	testCases := []struct {
+		desc   string
+		runner *Runner
+		f      func(e *E)
+		want   string
+		calls  int
+	}{{
+		desc: "next",
+		f:    func(e *E) { e.Must(err0, act(Next, err1), inc) },
+		want: "2",
+	}, {
+		desc: "next discard",
+		f:    func(e *E) { e.Must(err0, act(Next, nil), inc) },
+		want: "<nil>",
+	}, {
+		desc: "continue",
+		f: func(e *E) {
+			e.Must(err0, act(Continue, nil), inc)
+			e.Must(err2)
+		},
+		want: "2",
+	}, {
+		desc: "continue soft",
+		f: func(e *E) {
+			e.Must(err0, act(Continue, err1), inc)
+		},
+		want: "1",
+	}, {
+		desc: "continue defer",
+		f: func(e *E) {
+			e.Defer(func() error { return err1 }, act(Continue, err3))
+			e.Must(err2)
+		},
+		want: "3\n2",
+	}, {
+		desc: "abort",
+		f:    func(e *E) { e.Must(err0, act(Abort, err1), inc) },
+		want: "1",
+	}, {
+		desc: "abort keeps error",
+		f:    func(e *E) { e.Must(err1, act(Abort, nil), inc) },
+		want: "1",
+	}, {
+		desc: "abort check",
+		f: func(e *E) {
+			e.Check(err1, act(Abort, nil))
+			t.Error("Check did not abort")
+		},
+		want: "1",
+	}, {
+		desc: "abort scope",
+		f: func(e *E) {
+			e.Handle(inc)
+			e.Handle(act(Abort, err3))
+			e.Must(err0)
+		},
+		want: "3",
+	}, {
+		desc: "replace",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 }, act(Replace, nil))
+			e.Must(err1)
+		},
+		want: "2",
+	}, {
+		desc: "abort defer",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 }, act(Abort, nil))
+			e.Must(err1)
+		},
+		want: "1",
+	}, {
+		desc: "retry do",
+		f: func(e *E) {
+			e.Do(op(err1), retry(3, &calls))
+		},
+		want:  "1",
+		calls: 3,
+	}, {
+		desc: "retry do success",
+		f: func(e *E) {
+			e.Do(func() error {
+				if calls++; calls < 2 {
+					return err1
+				}
+				return nil
+			}, retry(5, &calls))
+		},
+		want:  "<nil>",
+		calls: 2,
+	}, {
+		desc: "retry defer",
+		f: func(e *E) {
+			e.Defer(op(err1), retry(4, &calls))
+		},
+		want:  "1",
+		calls: 4,
+	}, {
+		desc: "retry must",
+		f: func(e *E) {
+			e.Must(err1, act(Retry, nil), inc)
+		},
+		want: "1",
+	}, {
+		desc: "continue and defaults",
+		runner: WithDefault(ActionFunc(func(s State, err error) (Action, error) {
+			return Continue, err
+		})),
+		f: func(e *E) {
+			e.Must(err1, AndDefaults)
+			e.Must(err2, AndDefaults)
+			e.Must(err3, act(Abort, nil))
+		},
+		want: "1\n2\n3",
+	}, {
+		desc:   "retry best effort",
+		runner: WithDefault().With(BestEffortHandlers(retry(3, &calls))),
+		f: func(e *E) {
+			e.Defer(op(err1), BestEffort)
+		},
+		want:  "<nil>",
+		calls: 3,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			calls = 0
+			r := tc.runner
+			if r == nil {
+				r = WithDefault()
+			}
+			err := r.Run(tc.f)
+			if got := fmt.Sprint(err); got != tc.want {
+				t.Errorf("err: got %q; want %q", got, tc.want)
+			}
+			if calls != tc.calls {
+				t.Errorf("calls: got %d; want %d", calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestActionString(t *testing.T) {
+	for a, want := range map[Action]string{
+		Next:       "Next",
+		Abort:      "Abort",
+		Action(-3): "Action(-3)",
+	} {
+		if got := a.String(); got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	}
+}
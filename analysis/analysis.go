@@ -0,0 +1,241 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package analysis defines an Analyzer that reports misuse of package errd.
+//
+// The analyzer reports:
+//   - an *errd.E that is passed to or captured by a go statement, or stored in
+//     a struct field or a package-level variable,
+//   - a call to errd.Run, or another function of package errd returning an
+//     error, of which the result is ignored,
+//   - a call to Defer with a value of a type that Defer does not accept,
+//   - a call to Must on an E that was not passed as a parameter, and thus
+//     does not belong to a Run closure.
+package analysis
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const errdPath = "github.com/mpvl/errd"
+
+// Analyzer reports misuse of package errd.
+var Analyzer = &analysis.Analyzer{
+	Name:     "errd",
+	Doc:      "report misuse of package errd",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	// Package errd itself is free to use its internals.
+	if pass.Pkg.Path() == errdPath {
+		return nil, nil
+	}
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{
+		(*ast.GoStmt)(nil),
+		(*ast.DeferStmt)(nil),
+		(*ast.ExprStmt)(nil),
+		(*ast.StructType)(nil),
+		(*ast.ValueSpec)(nil),
+		(*ast.CallExpr)(nil),
+	}
+	inspect.WithStack(nodeFilter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.GoStmt:
+			checkGo(pass, n)
+			checkIgnored(pass, n.Call, "go statement")
+		case *ast.DeferStmt:
+			checkIgnored(pass, n.Call, "defer statement")
+		case *ast.ExprStmt:
+			if call, ok := n.X.(*ast.CallExpr); ok {
+				checkIgnored(pass, call, "expression statement")
+			}
+		case *ast.StructType:
+			for _, f := range n.Fields.List {
+				if isE(pass.TypesInfo.TypeOf(f.Type)) {
+					pass.Reportf(f.Pos(), "*errd.E stored in struct field")
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				obj := pass.TypesInfo.Defs[name]
+				if obj != nil && obj.Parent() == pass.Pkg.Scope() && isE(obj.Type()) {
+					pass.Reportf(name.Pos(), "*errd.E stored in package-level variable %s", name.Name)
+				}
+			}
+		case *ast.CallExpr:
+			checkMethodCall(pass, n, stack)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// isE reports whether t is errd.E or *errd.E.
+func isE(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == errdPath && obj.Name() == "E"
+}
+
+// checkGo reports an E that is passed to or captured by a goroutine.
+func checkGo(pass *analysis.Pass, g *ast.GoStmt) {
+	if sel, ok := g.Call.Fun.(*ast.SelectorExpr); ok && isE(pass.TypesInfo.TypeOf(sel.X)) {
+		pass.Reportf(sel.X.Pos(), "*errd.E used by goroutine")
+	}
+	for _, arg := range g.Call.Args {
+		if isE(pass.TypesInfo.TypeOf(arg)) {
+			pass.Reportf(arg.Pos(), "*errd.E passed to goroutine")
+		}
+	}
+	lit, ok := g.Call.Fun.(*ast.FuncLit)
+	if !ok {
+		return
+	}
+	reported := map[types.Object]bool{}
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj, ok := pass.TypesInfo.Uses[id].(*types.Var)
+		if !ok || reported[obj] || !isE(obj.Type()) {
+			return true
+		}
+		if obj.Pos() < lit.Pos() || obj.Pos() >= lit.End() {
+			reported[obj] = true
+			pass.Reportf(id.Pos(), "*errd.E %s captured by goroutine", id.Name)
+		}
+		return true
+	})
+}
+
+// checkIgnored reports a call to a function of package errd of which the
+// error result is discarded.
+func checkIgnored(pass *analysis.Pass, call *ast.CallExpr, where string) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != errdPath {
+		return
+	}
+	res := fn.Type().(*types.Signature).Results()
+	if res.Len() == 0 {
+		return
+	}
+	if last := res.At(res.Len() - 1).Type(); !types.Identical(last, errorType) {
+		return
+	}
+	pass.Reportf(call.Pos(), "result of errd %s is ignored in %s", fn.Name(), where)
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// checkMethodCall checks calls to methods of E.
+func checkMethodCall(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isE(pass.TypesInfo.TypeOf(sel.X)) {
+		return
+	}
+	switch sel.Sel.Name {
+	case "Defer":
+		if len(call.Args) > 0 {
+			checkDeferArg(pass, call.Args[0])
+		}
+	case "Must":
+		checkMust(pass, sel.X, stack)
+	}
+}
+
+// checkDeferArg reports a value passed to Defer that is known to be rejected
+// at run time.
+func checkDeferArg(pass *analysis.Pass, arg ast.Expr) {
+	t := pass.TypesInfo.TypeOf(arg)
+	if t == nil {
+		return
+	}
+	// Only named types have methods, so only an empty interface may hold one
+	// of the accepted function types.
+	if i, ok := t.Underlying().(*types.Interface); ok && i.NumMethods() == 0 {
+		return
+	}
+	if b, ok := t.(*types.Basic); ok && b.Kind() == types.UntypedNil {
+		return
+	}
+	for _, s := range deferSignatures(pass) {
+		if types.Identical(t, s) {
+			return
+		}
+	}
+	pass.Reportf(arg.Pos(), "type %s not supported by Defer", types.TypeString(t, types.RelativeTo(pass.Pkg)))
+}
+
+// deferSignatures returns the types accepted by Defer.
+func deferSignatures(pass *analysis.Pass) []types.Type {
+	errs := types.NewTuple(types.NewVar(0, nil, "", errorType))
+	sigs := []types.Type{
+		types.NewSignatureType(nil, nil, nil, nil, nil, false),
+		types.NewSignatureType(nil, nil, nil, nil, errs, false),
+		types.NewSignatureType(nil, nil, nil, errs, nil, false),
+		types.NewSignatureType(nil, nil, nil, errs, errs, false),
+	}
+	for _, p := range pass.Pkg.Imports() {
+		if p.Path() != errdPath {
+			continue
+		}
+		if obj := p.Scope().Lookup("State"); obj != nil {
+			state := types.NewTuple(types.NewVar(0, nil, "", obj.Type()))
+			sigs = append(sigs, types.NewSignatureType(nil, nil, nil, state, errs, false))
+		}
+	}
+	return sigs
+}
+
+// checkMust reports a call to Must on an E that is not a parameter of an
+// enclosing function.
+func checkMust(pass *analysis.Pass, x ast.Expr, stack []ast.Node) {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok {
+		return
+	}
+	obj := pass.TypesInfo.Uses[id]
+	if obj == nil {
+		return
+	}
+	for _, n := range stack {
+		var ft *ast.FuncType
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ft = n.Type
+		case *ast.FuncDecl:
+			ft = n.Type
+		default:
+			continue
+		}
+		for _, f := range ft.Params.List {
+			for _, name := range f.Names {
+				if pass.TypesInfo.Defs[name] == obj {
+					return
+				}
+			}
+		}
+	}
+	pass.Reportf(id.Pos(), "Must called on %s outside of a Run closure", id.Name)
+}
@@ -0,0 +1,79 @@
+package a
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/mpvl/errd"
+)
+
+var global *errd.E // want `\*errd.E stored in package-level variable global`
+
+type holder struct {
+	e *errd.E // want `\*errd.E stored in struct field`
+}
+
+func goroutines() error {
+	return errd.Run(func(e *errd.E) {
+		go func() {
+			e.Must(nil) // want `\*errd.E e captured by goroutine`
+		}()
+		go helper(e)           // want `\*errd.E passed to goroutine`
+		go e.Must(nil)         // want `\*errd.E used by goroutine`
+		go errd.Run(helper)    // want `result of errd Run is ignored in go statement`
+		defer errd.Run(helper) // want `result of errd Run is ignored in defer statement`
+	})
+}
+
+func helper(e *errd.E) {
+	e.Must(errors.New("foo"))
+	defer func() {
+		e.Must(nil)
+	}()
+}
+
+func ignored(ctx context.Context) {
+	errd.Run(helper)                 // want `result of errd Run is ignored in expression statement`
+	errd.RunWithContext(ctx, helper) // want `result of errd RunWithContext is ignored in expression statement`
+	errd.Default.Run(helper)         // want `result of errd Run is ignored in expression statement`
+	_ = errd.Run(helper)
+	if err := errd.Run(helper); err != nil {
+		return
+	}
+}
+
+type closer struct{}
+
+func (closer) Close() error                   { return nil }
+func (closer) CloseWithError(err error) error { return nil }
+func (closer) Abort(err error)                {}
+
+func defers(r io.ReadCloser, x interface{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = ctx
+	var c closer
+	return errd.Run(func(e *errd.E) {
+		e.Defer(nil)
+		e.Defer(x)
+		e.Defer(r.Close)
+		e.Defer(c.CloseWithError)
+		e.Defer(c.Abort)
+		e.Defer(func() {})
+		e.Defer(func(s errd.State) error { return s.Err() })
+		e.Defer(cancel)       // want `type context.CancelFunc not supported by Defer`
+		e.Defer(r)            // want `type io.ReadCloser not supported by Defer`
+		e.Defer(c)            // want `type closer not supported by Defer`
+		e.Defer(func(int) {}) // want `type func\(int\) not supported by Defer`
+	})
+}
+
+func must(err error) {
+	var e errd.E
+	e.Must(err) // want `Must called on e outside of a Run closure`
+
+	p := &e
+	p.Must(err) // want `Must called on p outside of a Run closure`
+
+	global.Must(err) // want `Must called on global outside of a Run closure`
+}
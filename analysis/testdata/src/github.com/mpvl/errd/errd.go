@@ -0,0 +1,37 @@
+// Package errd is a stub of package errd for testing.
+package errd
+
+import "context"
+
+type E struct{}
+
+// The analyzer does not report uses within package errd.
+type errorHandler struct {
+	e *E
+}
+
+func deferState(e *E) {
+	e.Defer(func(s State) error { return nil })
+}
+
+type Runner struct{}
+
+type State interface {
+	Err() error
+}
+
+type Handler interface {
+	Handle(s State, err error) error
+}
+
+var Default = &Runner{}
+
+func Run(f func(*E)) error { return nil }
+
+func RunWithContext(ctx context.Context, f func(*E)) error { return nil }
+
+func (r *Runner) Run(f func(*E)) error { return nil }
+
+func (e *E) Must(err error, h ...Handler) {}
+
+func (e *E) Defer(x interface{}, h ...Handler) {}
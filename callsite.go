@@ -0,0 +1,40 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import "errors"
+
+// CallSites is an Option that records the location of the call that reported
+// an error, such as a call to Must, Check, Fail, Failf or Assert. The
+// location can be retrieved with CallSite.
+//
+// CallSites adds some overhead to each reported error, but not to calls that
+// do not report an error.
+var CallSites Option = func(c *config) { c.callSite = true }
+
+// A callSiteError annotates an error with the location at which it was
+// reported. It does not alter the error message.
+type callSiteError struct {
+	err  error
+	site string
+}
+
+func withCallSite(err error) error {
+	return &callSiteError{err, callerSite()}
+}
+
+func (c *callSiteError) Error() string { return c.err.Error() }
+func (c *callSiteError) Unwrap() error { return c.err }
+
+// CallSite returns the location, in the form file:line, at which err was
+// reported within a Run, or "" if no location was recorded for err. Locations
+// are only recorded by Runners with the CallSites option.
+func CallSite(err error) string {
+	var c *callSiteError
+	if errors.As(err, &c) {
+		return c.site
+	}
+	return ""
+}
@@ -0,0 +1,387 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const errdPath = "github.com/mpvl/errd"
+
+// An edit replaces the source in [start, end) with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// fix rewrites all eligible functions in src to use errd. It returns the
+// resulting source and whether any function was rewritten.
+func fix(filename string, src []byte) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+	info := typeCheck(fset, f)
+
+	var edits []edit
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+			r := &rewriter{fset: fset, info: info, src: src}
+			if r.fixFunc(fd) {
+				edits = append(edits, r.edits...)
+			}
+		}
+	}
+	if len(edits) == 0 {
+		return src, false, nil
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+
+	if out, err = addImport(filename, out); err != nil {
+		return nil, false, err
+	}
+	if out, err = format.Source(out); err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// addImport adds an import of package errd to src, if needed. A new import is
+// added as a separate group if the last import is from the standard library.
+func addImport(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	var last *ast.GenDecl
+	for _, d := range f.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			last = d
+		}
+	}
+	for _, s := range f.Imports {
+		if path, _ := strconv.Unquote(s.Path.Value); path == errdPath {
+			return src, nil
+		}
+	}
+	quoted := strconv.Quote(errdPath)
+	var e edit
+	switch {
+	case last == nil:
+		end := fset.Position(f.Name.End()).Offset
+		e = edit{end, end, "\n\nimport " + quoted}
+	case !last.Lparen.IsValid():
+		spec := last.Specs[0].(*ast.ImportSpec)
+		start := fset.Position(last.Pos()).Offset
+		end := fset.Position(last.End()).Offset
+		e = edit{start, end, "import (\n" + string(src[fset.Position(spec.Pos()).Offset:end]) + sep(spec) + quoted + "\n)"}
+	default:
+		spec := last.Specs[len(last.Specs)-1].(*ast.ImportSpec)
+		end := fset.Position(spec.End()).Offset
+		e = edit{end, end, sep(spec) + quoted}
+	}
+	return append(src[:e.start:e.start], append([]byte(e.text), src[e.end:]...)...), nil
+}
+
+// sep returns the separator to use before a new import following spec.
+func sep(spec *ast.ImportSpec) string {
+	path, _ := strconv.Unquote(spec.Path.Value)
+	if first := strings.SplitN(path, "/", 2)[0]; !strings.Contains(first, ".") {
+		return "\n\n"
+	}
+	return "\n"
+}
+
+// typeCheck type checks f in isolation. The resulting information may be
+// incomplete, in which case rewrites fall back to heuristics.
+func typeCheck(fset *token.FileSet, f *ast.File) *types.Info {
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+	return info
+}
+
+type rewriter struct {
+	fset  *token.FileSet
+	info  *types.Info
+	src   []byte
+	edits []edit
+}
+
+func (r *rewriter) offset(p token.Pos) int {
+	return r.fset.Position(p).Offset
+}
+
+func (r *rewriter) text(n ast.Node) string {
+	return string(r.src[r.offset(n.Pos()):r.offset(n.End())])
+}
+
+func (r *rewriter) replace(n ast.Node, text string) {
+	r.edits = append(r.edits, edit{r.offset(n.Pos()), r.offset(n.End()), text})
+}
+
+// fixFunc records the edits to rewrite fd and reports whether fd is eligible.
+// A function is eligible if its only result is an error, if all its returns
+// can be converted to calls to Must, and if all its defers can be converted
+// to calls to Defer.
+func (r *rewriter) fixFunc(fd *ast.FuncDecl) bool {
+	if !returnsError(fd.Type) || usesName(fd, "e") || usesName(fd, "errd") {
+		return false
+	}
+	body := fd.Body
+	var last ast.Stmt
+	if n := len(body.List); n > 0 {
+		last = body.List[n-1]
+	}
+	eligible := true
+	changed := false
+	elseIf := map[*ast.IfStmt]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		if !eligible {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Returns and defers in function literals are not ours.
+			return false
+		case *ast.IfStmt:
+			if s, ok := n.Else.(*ast.IfStmt); ok {
+				elseIf[s] = true
+			}
+			if arg, ok := r.errCheck(n); ok {
+				if arg != "" && !elseIf[n] {
+					r.replace(n, "e.Must("+arg+")")
+				} else {
+					// Keep the if statement, as we cannot move its
+					// initialization statement.
+					ret := n.Body.List[0].(*ast.ReturnStmt)
+					r.replace(ret, "e.Must("+r.text(ret.Results[0])+")")
+				}
+				changed = true
+				return false
+			}
+		case *ast.ReturnStmt:
+			if n != last || len(n.Results) != 1 {
+				eligible = false
+				return false
+			}
+			if !r.isError(n.Results[0]) {
+				eligible = false
+				return false
+			}
+			if id, ok := n.Results[0].(*ast.Ident); ok && id.Name == "nil" {
+				// Also remove the indentation and line break preceding
+				// the return, but keep any comments before it.
+				start := r.offset(n.Pos())
+				for start > 0 && (r.src[start-1] == ' ' || r.src[start-1] == '\t') {
+					start--
+				}
+				if start > 0 && r.src[start-1] == '\n' {
+					start--
+				}
+				r.edits = append(r.edits, edit{start, r.offset(n.End()), ""})
+			} else {
+				r.replace(n, "e.Must("+r.text(n.Results[0])+")")
+			}
+		case *ast.DeferStmt:
+			x, ok := r.deferValue(n.Call)
+			if !ok || r.closedExplicitly(body, n.Call) {
+				eligible = false
+				return false
+			}
+			r.replace(n, "e.Defer("+x+")")
+			changed = true
+		}
+		return true
+	})
+	if !eligible || !changed {
+		return false
+	}
+	r.edits = append(r.edits,
+		edit{r.offset(body.Lbrace) + 1, r.offset(body.Lbrace) + 1, "\nreturn errd.Run(func(e *errd.E) {"},
+		edit{r.offset(body.Rbrace), r.offset(body.Rbrace), "})\n"},
+	)
+	return true
+}
+
+// returnsError reports whether the only result of ft is an unnamed error.
+// Named results are excluded, as they are typically accessed by deferred
+// function literals.
+func returnsError(ft *ast.FuncType) bool {
+	if ft.Results == nil || len(ft.Results.List) != 1 {
+		return false
+	}
+	f := ft.Results.List[0]
+	id, ok := f.Type.(*ast.Ident)
+	return ok && id.Name == "error" && len(f.Names) == 0
+}
+
+// usesName reports whether the identifier name occurs in n.
+func usesName(n ast.Node, name string) (found bool) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// errCheck matches an if statement of the form
+//
+//	if err != nil { return err }
+//	if err := f(); err != nil { return err }
+//
+// and returns the argument to pass to Must, replacing the if statement. It
+// returns an empty argument for a matching if statement with another kind of
+// initialization statement.
+func (r *rewriter) errCheck(s *ast.IfStmt) (arg string, ok bool) {
+	if s.Else != nil || len(s.Body.List) != 1 {
+		return "", false
+	}
+	ret, ok := s.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return "", false
+	}
+	v, ok := ret.Results[0].(*ast.Ident)
+	if !ok || !r.isError(v) {
+		return "", false
+	}
+	cond, ok := s.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ || !isIdent(cond.X, v.Name) || !isIdent(cond.Y, "nil") {
+		return "", false
+	}
+	switch init := s.Init.(type) {
+	case nil:
+		return v.Name, true
+	case *ast.AssignStmt:
+		if init.Tok == token.DEFINE && len(init.Lhs) == 1 && len(init.Rhs) == 1 && isIdent(init.Lhs[0], v.Name) {
+			return r.text(init.Rhs[0]), true
+		}
+	}
+	return "", true
+}
+
+// isError reports whether x has type error. A nil pointer of a concrete
+// error type is not nil once converted to error, so passing it to Must would
+// fail. It reports true if the type of x is unknown.
+func (r *rewriter) isError(x ast.Expr) bool {
+	t := r.info.TypeOf(x)
+	if t == nil || t == types.Typ[types.Invalid] || isIdent(x, "nil") {
+		return true
+	}
+	return types.Identical(t, errorType)
+}
+
+func isIdent(x ast.Expr, name string) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// deferValue returns the value to pass to Defer for a deferred call. Only
+// calls without arguments of closing methods, as reported by closing, are
+// converted, as other deferred functions, like a Rollback following a Commit,
+// may return errors that are deliberately ignored. If type information is
+// available, the method must also have a signature supported by Defer. A
+// Close method is replaced by CloseWithError if available.
+func (r *rewriter) deferValue(call *ast.CallExpr) (string, bool) {
+	if len(call.Args) != 0 {
+		return "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !closing(sel.Sel.Name) {
+		return "", false
+	}
+	t := r.info.TypeOf(call.Fun)
+	if t != nil && t != types.Typ[types.Invalid] && !supported(t) {
+		return "", false
+	}
+	if sel.Sel.Name == "Close" && hasCloseWithError(r.info.TypeOf(sel.X)) {
+		return r.text(sel.X) + ".CloseWithError", true
+	}
+	return r.text(call.Fun), true
+}
+
+// closing reports whether a method with the given name releases a resource.
+func closing(name string) bool {
+	switch name {
+	case "Close", "CloseWithError", "Unlock", "RUnlock":
+		return true
+	}
+	return false
+}
+
+// closedExplicitly reports whether the receiver of the deferred call is also
+// closed by another call in body. Converting the defer would then report the
+// error of closing it twice, for instance "file already closed".
+func (r *rewriter) closedExplicitly(body *ast.BlockStmt, deferred *ast.CallExpr) (found bool) {
+	recv := r.text(deferred.Fun.(*ast.SelectorExpr).X)
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || call == deferred {
+			return !found
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && closing(sel.Sel.Name) && r.text(sel.X) == recv {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+var (
+	errorType  = types.Universe.Lookup("error").Type()
+	errorTuple = types.NewTuple(types.NewVar(token.NoPos, nil, "", errorType))
+
+	// deferSignatures lists the types supported by Defer, except for
+	// func(errd.State) error, which is not a candidate for conversion.
+	deferSignatures = []types.Type{
+		types.NewSignatureType(nil, nil, nil, nil, nil, false),
+		types.NewSignatureType(nil, nil, nil, nil, errorTuple, false),
+		types.NewSignatureType(nil, nil, nil, errorTuple, nil, false),
+		types.NewSignatureType(nil, nil, nil, errorTuple, errorTuple, false),
+	}
+)
+
+func supported(t types.Type) bool {
+	for _, s := range deferSignatures {
+		if types.Identical(t, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCloseWithError reports whether t has a method CloseWithError(error) error.
+func hasCloseWithError(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "CloseWithError")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return types.Identical(types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), false), deferSignatures[3])
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range files {
+		t.Run(filepath.Base(in), func(t *testing.T) {
+			src, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := fix(in, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(in, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output does not match %s; got:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	src := []byte("package p\n\nfunc f() error {\n\treturn nil\n}\n")
+	got, changed, err := fix("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || !bytes.Equal(got, src) {
+		t.Errorf("got changed=%v:\n%s\nwant unchanged", changed, got)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command errdfix rewrites functions to use package errd.
+//
+// Usage:
+//
+//	errdfix [flags] [path ...]
+//
+// Errdfix rewrites functions of which the only result is an error. The body
+// of such a function is wrapped in a call to errd.Run, error checks of the
+// form
+//
+//	if err != nil {
+//		return err
+//	}
+//
+// are replaced with calls to e.Must, and deferred calls to the Close,
+// CloseWithError, Unlock and RUnlock methods are replaced with calls to
+// e.Defer. Close is replaced with CloseWithError where available. A function
+// is only rewritten if all its returns and defers can be converted. Other
+// deferred calls, like Rollback, and defers of receivers that are also closed
+// explicitly are not converted.
+//
+// Note that errors returned by deferred functions are no longer ignored after
+// the conversion.
+//
+// Without an explicit path, errdfix processes the standard input. Given a
+// file, it operates on that file; given a directory, it operates on all .go
+// files in that directory, recursively. By default, errdfix prints the
+// rewritten sources to standard output.
+//
+// The flags are:
+//
+//	-d
+//		Do not print rewritten sources. Instead, print diffs to
+//		standard output.
+//	-w
+//		Do not print rewritten sources to standard output. Instead,
+//		overwrite the files that were changed.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
+	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
+)
+
+var exitCode = 0
+
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	exitCode = 2
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: errdfix [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			report(fmt.Errorf("errdfix: cannot use -w with standard input"))
+		} else if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, path := range flag.Args() {
+		switch fi, err := os.Stat(path); {
+		case err != nil:
+			report(err)
+		case fi.IsDir():
+			filepath.Walk(path, visitFile)
+		default:
+			if err := processFile(path, nil, os.Stdout); err != nil {
+				report(err)
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func visitFile(path string, fi os.FileInfo, err error) error {
+	if err == nil && !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+		err = processFile(path, nil, os.Stdout)
+	}
+	if err != nil {
+		report(err)
+	}
+	return nil
+}
+
+// processFile rewrites the file with the given name. If in is nil, the
+// source is read from the file.
+func processFile(filename string, in io.Reader, out io.Writer) error {
+	if in == nil {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, changed, err := fix(filename, src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *doDiff:
+		if !changed {
+			return nil
+		}
+		d, err := diff(filename, src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %s", err)
+		}
+		_, err = out.Write(d)
+		return err
+	case *write:
+		if !changed {
+			return nil
+		}
+		return os.WriteFile(filename, res, 0644)
+	}
+	_, err = out.Write(res)
+	return err
+}
+
+// diff returns a unified diff of b1 and b2 using the diff tool.
+func diff(filename string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTempFile("errdfix", b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTempFile("errdfix", b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command("diff", "-u", "-L", filename+".orig", "-L", filename, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		err = nil
+	}
+	return bytes.TrimSuffix(data, []byte("\\ No newline at end of file\n")), err
+}
+
+func writeTempFile(prefix string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command errdvet reports misuse of package errd.
+//
+// Usage:
+//
+//	errdvet [flags] packages
+//
+// See package github.com/mpvl/errd/analysis for the checks that are performed.
+package main
+
+import (
+	"github.com/mpvl/errd/analysis"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analysis.Analyzer)
+}
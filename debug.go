@@ -0,0 +1,76 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Debug is an Option that enables the detection of misuse of E. An E that is
+// used after its call to Run returned, or from a goroutine other than the one
+// that called Run, causes a panic that reports the location of the call to Run
+// that created it.
+//
+// Debug adds considerable overhead and is intended for tests and debugging.
+// It is enabled for all Runners when building with the errddebug tag.
+var Debug Option = func(c *config) { c.debug = true }
+
+type debugInfo struct {
+	site      string // location of the call to Run
+	goroutine uint64 // goroutine that called Run
+	done      bool   // Run returned
+}
+
+// newDebugInfo returns the debugInfo for a new scope. It records the
+// location of the first caller outside of package errd.
+func newDebugInfo() *debugInfo {
+	return &debugInfo{site: callerSite(), goroutine: goid()}
+}
+
+// callerSite returns the location of the first caller outside of package
+// errd.
+func callerSite() string {
+	var pcs [16]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		f, more := frames.Next()
+		// Skip the runtime frames of a panic as well.
+		internal := strings.HasPrefix(f.Function, pkgPrefix) || strings.HasPrefix(f.Function, "runtime.")
+		if !internal || strings.HasSuffix(f.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown location"
+		}
+	}
+}
+
+const pkgPrefix = "github.com/mpvl/errd."
+
+func (d *debugInfo) check() {
+	if d.done {
+		panic(fmt.Errorf("errd: E used after Run returned (Run called at %s)", d.site))
+	}
+	if goid() != d.goroutine {
+		panic(fmt.Errorf("errd: E used from a goroutine other than the one that called Run (Run called at %s)", d.site))
+	}
+}
+
+// goid returns the ID of the current goroutine.
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	// The stack trace starts with "goroutine <id> [".
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
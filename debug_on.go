@@ -0,0 +1,11 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build errddebug
+// +build errddebug
+
+package errd
+
+// debugDefault enables Debug for all Runners.
+const debugDefault = true
@@ -0,0 +1,109 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	ec := WithDefault().With(Debug)
+	var escaped *E
+	_, _, line, _ := runtime.Caller(0)
+	ec.Run(func(e *E) { escaped = e })
+	site := fmt.Sprintf("debug_test.go:%d", line+1)
+
+	testCases := []struct {
+		desc string
+		f    func(e *E)
+		want string
+	}{{
+		desc: "Must after Run",
+		f:    func(e *E) { e.Must(nil) },
+		want: "used after Run returned",
+	}, {
+		desc: "Must error after Run",
+		f:    func(e *E) { e.Must(errors.New("foo")) },
+		want: "used after Run returned",
+	}, {
+		desc: "Defer after Run",
+		f:    func(e *E) { e.Defer(func() {}) },
+		want: "used after Run returned",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil {
+					t.Fatal("expected panic")
+				}
+				if msg := err.Error(); !strings.Contains(msg, tc.want) || !strings.Contains(msg, site) {
+					t.Errorf("got %q; want message containing %q and %q", msg, tc.want, site)
+				}
+			}()
+			tc.f(escaped)
+		})
+	}
+}
+
+func TestDebugGoroutine(t *testing.T) {
+	ec := WithDefault().With(Debug)
+	var msg string
+	ec.Run(func(e *E) {
+		done := make(chan bool)
+		go func() {
+			defer func() {
+				if err, ok := recover().(error); ok {
+					msg = err.Error()
+				}
+				done <- true
+			}()
+			e.Defer(func() {})
+		}()
+		<-done
+	})
+	if want := "other than the one that called Run"; !strings.Contains(msg, want) {
+		t.Errorf("got %q; want message containing %q", msg, want)
+	}
+}
+
+func TestDebugValidUse(t *testing.T) {
+	ec := WithDefault().With(Debug)
+	errFoo := errors.New("foo")
+	closed := false
+	err := ec.Run(func(e *E) {
+		e.Defer(func() { closed = true })
+		e.Must(nil)
+		e.Must(errFoo)
+	})
+	if err != errFoo {
+		t.Errorf("got %v; want %v", err, errFoo)
+	}
+	if !closed {
+		t.Error("defer was not called")
+	}
+}
+
+func TestDebugNested(t *testing.T) {
+	ec := WithDefault().With(Debug)
+	var msg string
+	ec.Run(func(e *E) {
+		var inner *E
+		e.Run(func(e *E) { inner = e })
+		defer func() {
+			if err, ok := recover().(error); ok {
+				msg = err.Error()
+			}
+		}()
+		inner.Must(nil)
+	})
+	if want := "used after Run returned"; !strings.Contains(msg, want) {
+		t.Errorf("got %q; want message containing %q", msg, want)
+	}
+}
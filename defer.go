@@ -35,6 +35,9 @@ type deferFunc func(s State, x interface{}) error
 // DeferFunc can be used to avoid the allocation typically incurred
 // with Defer.
 func (e *E) deferFunc(x interface{}, f deferFunc, h ...Handler) {
+	if e.debug != nil {
+		e.debug.check()
+	}
 	if f == nil {
 		panic(errNilFunc)
 	}
@@ -106,10 +109,10 @@ func stateErrorFunc(s State, x interface{}) error {
 //
 // Performance-sensitive applications should use DeferFunc.
 func (e *E) Defer(x interface{}, h ...Handler) {
+	if e.debug != nil {
+		e.debug.check()
+	}
 	if x != nil {
-		for i := len(h) - 1; i >= 0; i-- {
-			e.deferred = append(e.deferred, deferData{h[i], nil})
-		}
 		var f deferFunc
 		switch x.(type) {
 		case func():
@@ -125,22 +128,32 @@ func (e *E) Defer(x interface{}, h ...Handler) {
 		default:
 			panic(fmt.Errorf(notSupported, x))
 		}
+		if i := e.runner.intercept; i != nil {
+			i.Defer(x, append([]Handler(nil), h...), e.scopedHandlers())
+			return
+		}
+		for i := len(h) - 1; i >= 0; i-- {
+			e.deferred = append(e.deferred, deferData{h[i], nil})
+		}
 		e.deferred = append(e.deferred, deferData{x, f})
 	}
 }
 
 const notSupported = "errd: type %T not supported by Defer"
 
-// TODO
-//
-// // DeferScope calls f and calls all defers that were added within that call
-// // after it completes. An error that occurs in f is handled as if the error
-// // occurred in the caller. This includes errors in defer. DeferScope is used to
-// // force early cleanup of defers within a tight loop.
-// func (e *E) DeferScope(f func()) {
-// 	localDefer := len(e.deferred)
-// 	f()
-// 	doDefers(e, localDefer)
-
-// 	// TODO: bail if we detect an error.
-// }
+// DeferScope calls f and calls all defers that were added within that call
+// after it completes. Handlers added by Handle within that call are removed
+// as well. An error that occurs in f is handled as if the error occurred in
+// the caller. This includes errors in defer. DeferScope is used to force early
+// cleanup of defers within a tight loop.
+func (e *E) DeferScope(f func()) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	localDefer := len(e.deferred)
+	f()
+	doDefers(e, localDefer)
+	if e.err != nil {
+		bail(e)
+	}
+}
@@ -178,3 +178,27 @@ func BenchmarkDeferClosure(b *testing.B) {
 		}
 	})
 }
+
+func TestDeferScope(t *testing.T) {
+	errTest := errors.New("test")
+	var result string
+	err := Run(func(e *E) {
+		e.Defer(func() { result += ":outer" })
+		for i := 0; i < 2; i++ {
+			e.DeferScope(func() {
+				e.Defer(func() { result += ":inner" })
+			})
+			result += ":loop"
+		}
+		e.DeferScope(func() {
+			e.Defer(func() error { return errTest })
+		})
+		result += ":unreachable"
+	})
+	if err != errTest {
+		t.Errorf("err: got %v; want %v", err, errTest)
+	}
+	if want := ":inner:loop:inner:loop:outer"; result != want {
+		t.Errorf("result: got %q; want %q", result, want)
+	}
+}
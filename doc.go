@@ -114,6 +114,45 @@
 // makes it easy to enforce decorating errors. Error handlers can also be used
 // to pass up HTTP error codes, log errors, attach metrics, etc.
 //
+// Handle adds handlers for the remainder of a scope. This avoids repeating
+// the same handler for each call to Must:
+//
+//     func writeToGS(ctx context.Context, bucket, dst, src string) error {
+//         return errd.Run(func(e *errd.E) {
+//             e.Handle(msg("error writing to GS"))
+//             client, err := storage.NewClient(ctx)
+//             e.Must(err)
+//             ...
+//         })
+//     }
+//
+// DeferScope limits the scope of such handlers, as well as that of defers.
+//
+// For the common case of adding a description of the operation that failed,
+// Named and Op replace the msg handler. Labels are prefixed to any error
+// returned by Run and can be retrieved with Ops:
+//
+//     var ecGS = errd.WithDefault().With(errd.Named("gs"))
+//
+//     func writeToGS(ctx context.Context, bucket, dst, src string) error {
+//         return ecGS.Run(func(e *errd.E) {
+//             e.Op("open client")
+//             client, err := storage.NewClient(ctx)
+//             e.Must(err) // gs: open client: ...
+//             ...
+//         })
+//     }
+//
+// Check is like Must, but records an error and continues. This allows
+// reporting all problems found during validation instead of only the first:
+//
+//     func validate(c *Config) error {
+//         return errd.Run(func(e *errd.E) {
+//             e.Check(checkName(c.Name))
+//             e.Check(checkPort(c.Port))
+//         })
+//     }
+//
 //
 // Returning Values
 //
@@ -131,6 +170,16 @@
 //
 // If name is only set at the end, any error will force an early return from Run
 // and leave name empty. Otherwise, name will be set and err will be nil.
+//
+// Alternatively, Try returns the value returned by the function it is passed,
+// or the zero value if an error occurred:
+//
+//    func foo() (string, error) {
+//        return errd.Try(func(e *errd.E) string {
+//            //   Some fun code here.
+//            return "bar"
+//        })
+//    }
 package errd
 
 // TODO
@@ -141,12 +190,10 @@ package errd
 //
 // Consider these API changes:
 //
-// - Option to chose error, where a newly encountered error may be returned
-//   instead of a previously selected one. Could be an option Replace in State.
 // - Allow functions of signature func(), func(error), func() error, and
 //   func(error) error, to be passed to Defer.
 //
 // For v2, if performance allows::
 // - make E an interface: this allows injecting with test simulator.
 //   Would be great for such functionality. Go docs will not look as nice,
-//   though.
+//   though. For now, errdtest.Recorder covers the most common testing needs.
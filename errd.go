@@ -8,6 +8,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/mpvl/errd/internal/hook"
 )
 
 // Default is the default Runner comfiguration.
@@ -17,12 +20,12 @@ var (
 
 // Run calls Default.Run(f)
 func Run(f func(*E)) (err error) {
-	return Default.Run(f)
+	return Default.run(nil, f)
 }
 
 // RunWithContext calls Default.RunWithContext(ctxt, f)
 func RunWithContext(ctxt context.Context, f func(*E)) (err error) {
-	return Default.RunWithContext(ctxt, f)
+	return Default.run(ctxt, f)
 }
 
 // A Runner defines a default way to handle errors and options.
@@ -36,63 +39,234 @@ func WithDefault(h ...Handler) *Runner {
 	return &Runner{
 		config: &config{
 			defaultHandlers: h,
+			debug:           debugDefault,
 		},
 	}
 }
 
+// An Option configures a Runner.
+type Option func(c *config)
+
+// With returns a copy of r with the given options applied.
+func (r *Runner) With(opts ...Option) *Runner {
+	c := *r.config
+	for _, o := range opts {
+		o(&c)
+	}
+	x := *r
+	x.config = &c
+	return &x
+}
+
+// Named returns an Option that sets the name of the operation performed by
+// the Runner. The name precedes the labels added by Op in errors returned by
+// Run and is reported by handlers created by Logger.
+func Named(op string) Option {
+	return func(c *config) { c.name = op }
+}
+
 // Run starts a new error handling scope. The function returns whenever an error
 // is encountered with one of the methods on E.
 func (r *Runner) Run(f func(e *E)) (err error) {
-	// state := c.state
-	var e E
-	e.runner = r.config
-	e.deferred = e.buf[:0]
-	defer doRecover(&e, &err)
-	f(&e)
-	doDefers(&e, 0)
-	if e.err != nil {
-		return *e.err
-	}
-	return nil
+	return r.run(nil, f)
 }
 
 // RunWithContext starts a new error handling scope. The function returns
 // whenever an error is encountered with one of the methods on E.
 func (r *Runner) RunWithContext(ctxt context.Context, f func(e *E)) (err error) {
-	// Do defers now to save on an extra defer.
-	// state := c.state
+	return r.run(ctxt, f)
+}
+
+// run implements all variants of Run.
+func (r *Runner) run(ctxt context.Context, f func(e *E)) (err error) {
 	var e E
-	e.runner = r.config
-	e.deferred = e.buf[:0]
-	e.context = ctxt
+	e.init(r.config, ctxt)
+	if len(r.hooks) > 0 {
+		e.startHooks()
+	}
 	defer doRecover(&e, &err)
 	f(&e)
+	return e.finish()
+}
+
+// init initializes e for a new scope.
+func (e *E) init(c *config, ctxt context.Context) {
+	e.runner = c
+	e.deferred = e.buf[:0]
+	e.context = ctxt
+	if c.debug {
+		e.debug = newDebugInfo()
+	}
+}
+
+// root returns the top-level scope of e.
+func (e *E) root() *E {
+	if e.top != nil {
+		return e.top
+	}
+	return e
+}
+
+// finish runs the remaining defers of a scope that completed without bailing
+// and returns the resulting error.
+func (e *E) finish() error {
 	// Do defers now to save on an extra defer.
-	doDefers(&e, 0)
+	doDefers(e, 0)
+	return e.result()
+}
+
+// result returns the error to be returned by Run: the error that caused e to
+// fail, combined with any soft errors.
+func (e *E) result() error {
+	var err error
 	if e.err != nil {
-		return *e.err
+		err = *e.err
+	}
+	switch {
+	case len(e.soft) == 0:
+		return err
+	case len(e.soft) == 1 && err == nil:
+		return e.soft[0]
+	}
+	return errors.Join(append(e.soft[:len(e.soft):len(e.soft)], err)...)
+}
+
+// Run calls f in a nested scope. The nested scope inherits the context, the
+// configuration, the handlers added by Handle, and the labels and fields added
+// by Op and With from e. Defers added within f are run when f returns. If the
+// nested scope fails, e fails with the same error, without passing it through
+// the handlers of e again. Soft errors recorded by Check within f are
+// recorded in e, without failing it.
+func (e *E) Run(f func(e *E)) {
+	var c E
+	e.nested(&c, f)
+	e.soft = append(e.soft, c.soft...)
+	if c.err != nil && *c.err != nil {
+		e.setErr(*c.err)
+		bail(e)
 	}
-	return nil
+}
+
+// Try is like Run, but returns the error of the nested scope, combined with
+// its soft errors, instead of failing e.
+func (e *E) Try(f func(e *E)) error {
+	var c E
+	e.nested(&c, f)
+	return c.result()
+}
+
+// nested runs f with c as a nested scope of e. The errors of the scope are
+// recorded in c.
+func (e *E) nested(c *E, f func(e *E)) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	c.init(e.runner, e.context)
+	c.top = e.root()
+	for _, d := range e.deferred {
+		switch d.x.(type) {
+		case handlerScope, opLabel, Field:
+			c.deferred = append(c.deferred, d)
+		}
+	}
+	var err error
+	defer doRecover(c, &err)
+	f(c)
+	c.finish()
 }
 
 type config struct {
 	defaultHandlers []Handler
 
+	// intercept, if non-nil, is notified of calls to Must, Check, and Defer.
+	// It is used by package errdtest.
+	intercept hook.Interceptor
+
+	// debug enables checks for misuse of E.
+	debug bool
+
+	// callSite enables recording the location at which errors are reported.
+	callSite bool
+
+	// deferHandlers, if non-nil, replaces defaultHandlers for errors returned
+	// by deferred functions.
+	deferHandlers []Handler
+
+	// bestEffort holds the handlers applied by BestEffort.
+	bestEffort []Handler
+
+	// name is the name of the operation performed by the Runner.
+	name string
+
+	// hooks are called at points in the lifecycle of a Run.
+	hooks []*Hooks
+
+	// logOnce causes handlers created by Logger to log when Run returns.
+	logOnce bool
+
+	// redact, if non-nil, is applied to errors before passing them to
+	// handlers.
+	redact func(error) error
+
+	// alwaysDefaults causes the default handlers to be applied after any
+	// other handlers.
+	alwaysDefaults bool
+
+	// selector, if non-nil, chooses between the recorded error and a newly
+	// encountered one. If nil, the first error is kept.
+	selector Selector
+
 	// inPanic indicates a panic is occurring: a copy of this Config with inPanic
 	// set is assigned to the state if a panic occurs. This removes this field
 	// from core.
 	inPanic bool
 }
 
+func init() {
+	hook.Intercept = func(runner interface{}, i hook.Interceptor) interface{} {
+		return runner.(*Runner).With(func(c *config) { c.intercept = i })
+	}
+}
+
 const bufSize = 3
 
 type core struct {
-	// Fits into 128 bytes; 2 cache lines on many modern architectures.
+	// The fields up to debug fit into 128 bytes; 2 cache lines on many modern
+	// architectures.
 	runner   *config
 	deferred []deferData
 	buf      [bufSize]deferData
 	err      *error
 	context  context.Context
+
+	// debug is only set if the Runner has debugging enabled.
+	debug *debugInfo
+
+	// soft holds the errors recorded by Check.
+	soft []error
+
+	// inDefer is set while handling an error returned by a deferred function.
+	inDefer bool
+
+	// replaced is set if a handler called State.Replace while handling the
+	// current error. The error is then not passed to the Selector.
+	replaced bool
+
+	// appliedDefaults is set if AndDefaults applied the default handlers
+	// while handling the current error, so that AlwaysDefaults does not apply
+	// them again.
+	appliedDefaults bool
+
+	// logged holds the record to log when Run returns, if LogOnce is set. It
+	// is only set for a top-level scope.
+	logged *logRecord
+
+	// top is the top-level scope of a nested scope and nil otherwise.
+	top *E
+
+	// start is the time a top-level scope started. It is only set if the
+	// Runner has hooks.
+	start time.Time
 }
 
 // An E coordinates the error and defer handling.
@@ -103,11 +277,103 @@ var errHandlerFirst = errors.New("errd: handler may not be first argument")
 // Must causes a call to Run to return on error. An error is detected if err
 // is non-nil and if it is still non-nil after passing it to error handling.
 func (e *E) Must(err error, h ...Handler) {
+	if e.debug != nil {
+		e.debug.check()
+	}
 	if err != nil {
 		processError(e, err, h)
 	}
 }
 
+// Check is like Must, but does not cause Run to return. Instead, an error that
+// remains after error handling is recorded as a soft error and execution
+// continues. Check reports whether no such error remained.
+//
+// Run returns the soft errors, combined with errors.Join, together with the
+// error that caused it to return, if any. Soft errors do not affect the error
+// passed to deferred functions.
+func (e *E) Check(err error, h ...Handler) bool {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	if err == nil {
+		return true
+	}
+	if i := e.runner.intercept; i != nil {
+		i.Check(err, append([]Handler(nil), h...))
+	}
+	err, a := applyHandlers(e, err, h)
+	switch {
+	case err == nil:
+		return true
+	case a == Retry, a == Replace, a == Abort:
+		fail(e, err, a)
+	}
+	e.addSoft(err)
+	return false
+}
+
+// Do calls f and passes the error it returns, if any, to Must with the given
+// handlers. If an ActionHandler requests a Retry, f is called again.
+func (e *E) Do(f func() error, h ...Handler) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	for {
+		err := f()
+		if err == nil || !handleError(e, err, h, true) {
+			return
+		}
+	}
+}
+
+// Fail causes a call to Run to return with err, after passing it to the
+// handlers added by Handle or the default handlers. Fail panics if err is nil.
+func (e *E) Fail(err error) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	if err == nil {
+		panic(errNilError)
+	}
+	processError(e, err, nil)
+}
+
+var errNilError = errors.New("errd: Fail called with nil error")
+
+// Failf is like Fail, but creates the error using fmt.Errorf.
+func (e *E) Failf(format string, args ...interface{}) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	processError(e, fmt.Errorf(format, args...), nil)
+}
+
+// Assert calls Failf with the given format and arguments if cond is false.
+func (e *E) Assert(cond bool, format string, args ...interface{}) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	if !cond {
+		processError(e, fmt.Errorf(format, args...), nil)
+	}
+}
+
+// Return causes a call to Run to return early without an error. All deferred
+// functions are run as usual and observe a nil error. Run still returns any
+// errors recorded by Check, or an error returned by a deferred function.
+//
+// Return can be used by helper functions that are passed an E to end the
+// scope. Within a nested scope, Return only ends the nested scope. Within
+// Try and TryWith, Return ends the scope before f returns a value, so that
+// they return the zero value and a nil error.
+func (e *E) Return() {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	bail(e)
+}
+
 // State represents the error state passed to custom error handlers.
 type State interface {
 	// Context returns the context set by WithContext, or context.TODO
@@ -121,8 +387,31 @@ type State interface {
 
 	// Err reports the first error that passed through an error handler chain.
 	// Note that this is always a different error (or nil) than the one passed
-	// to an error handler.
+	// to an error handler. Soft errors are not included.
 	Err() error
+
+	// SoftErrors reports the errors recorded by Check so far.
+	SoftErrors() []error
+
+	// Name returns the name of the Runner, as set with Named.
+	Name() string
+
+	// Ops returns the labels added with Op that are in scope, preceded by
+	// the name of the Runner, if any. These are the labels that are prefixed
+	// to the error if it causes Run to return.
+	Ops() []string
+
+	// Values returns the fields added with With that are in scope.
+	Values() []Field
+
+	// InDefer reports whether the error being handled was returned by a
+	// deferred function, rather than passed to Must or a similar method.
+	InDefer() bool
+
+	// Replace sets the error to be returned by Run to err, regardless of the
+	// Selector of the Runner. A nil error is ignored, as is a call made while
+	// panicking. Handlers continue to be applied to the error passed to them.
+	Replace(err error)
 }
 
 type state struct{ core }
@@ -143,29 +432,100 @@ func (s *state) Err() error {
 	return *s.err
 }
 
+func (s *state) SoftErrors() []error { return s.soft }
+
+func (s *state) Name() string { return s.runner.name }
+
+func (s *state) Ops() []string { return (*E)(s).ops() }
+
+func (s *state) Values() []Field { return (*E)(s).values() }
+
+func (s *state) InDefer() bool { return s.inDefer }
+
+func (s *state) Replace(err error) {
+	if err != nil && !s.runner.inPanic {
+		s.err = &err
+		s.replaced = true
+	}
+}
+
 var errOurPanic = errors.New("errd: our panic")
 
+// doRecover is deferred by Run. It handles any panic and completes the
+// error handling scope.
 func doRecover(e *E, err *error) {
-	switch r := recover(); r {
+	r := recover()
+	handleRecover(e, err, r)
+	if e.logged != nil || !e.start.IsZero() {
+		final := *err
+		if r != nil && r != errOurPanic {
+			final = e.result()
+		}
+		if e.logged != nil && final != nil {
+			e.logged.log(final)
+		}
+		if !e.start.IsZero() {
+			e.finishHooks(final)
+		}
+	}
+	if e.debug != nil {
+		e.debug.done = true
+	}
+	if r != nil && r != errOurPanic {
+		panic(r)
+	}
+}
+
+// doRecoverDefer is like doRecover, but is used while processing remaining
+// defers.
+func doRecoverDefer(e *E, err *error) {
+	r := recover()
+	handleRecover(e, err, r)
+	if r != nil && r != errOurPanic {
+		panic(r)
+	}
+}
+
+// A panicError is the error for a panic with a value that is not an error.
+type panicError struct {
+	value interface{}
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("errd: paniced: %v", p.value)
+}
+
+func handleRecover(e *E, err *error, r interface{}) {
+	switch r {
 	case nil:
 	case errOurPanic:
 		finishDefer(e, err)
-		*err = *e.err
+		*err = e.result()
 	default:
+		err2, ok := r.(error)
+		if !ok {
+			err2 = &panicError{r}
+		}
 		if !e.runner.inPanic {
 			c := *e.runner
 			c.inPanic = true
 			e.runner = &c
+			// Only the top-level scope passes the error to the handlers,
+			// as a nested scope passes on the panic. The handlers cannot
+			// rewrite the error, but may, for instance, log it.
+			if e.top == nil {
+				applyHandlers(e, err2, nil)
+			}
 		}
-		err2, ok := r.(error)
-		if !ok {
-			err2 = fmt.Errorf("errd: paniced: %v", r)
+		// Only the top-level scope calls the hooks, as a nested scope
+		// passes on the panic.
+		if len(e.runner.hooks) > 0 && !e.start.IsZero() {
+			e.panicHooks(r)
 		}
 		e.err = &err2
 		finishDefer(e, err)
-		// Check whether there are still defers left to do and then
-		// recursively defer.
-		panic(r)
+		// The caller panics again to pass on the panic after all defers
+		// have been processed.
 	}
 }
 
@@ -177,8 +537,16 @@ func doDefers(e *E, barrier int) {
 		if d.f == nil {
 			continue
 		}
-		if err := d.f((*state)(e), d.x); err != nil {
-			processDeferError(e, err)
+		for {
+			var err error
+			if len(e.runner.hooks) > 0 {
+				err = e.runDeferHooked(d)
+			} else {
+				err = d.f((*state)(e), d.x)
+			}
+			if err == nil || !processDeferError(e, err) {
+				break
+			}
 		}
 	}
 }
@@ -188,69 +556,214 @@ func doDefers(e *E, barrier int) {
 // older panic after returning.
 func finishDefer(e *E, err *error) {
 	if len(e.deferred) > 0 {
-		defer doRecover(e, err)
+		defer doRecoverDefer(e, err)
 		doDefers(e, 0)
 	}
 }
 
 type errorHandler struct {
-	e   *E
-	err *error
+	e      *E
+	err    *error
+	action *Action
 }
 
 func (h errorHandler) handle(eh Handler) (done bool) {
-	newErr := eh.Handle((*state)(h.e), *h.err)
-	if newErr == nil {
-		return true
+	var newErr error
+	if ah, ok := eh.(ActionHandler); ok {
+		var a Action
+		a, newErr = ah.HandleAction((*state)(h.e), *h.err)
+		if a != Next {
+			if newErr != nil || a == Continue {
+				*h.err = newErr
+			}
+			*h.action = a
+			return true
+		}
+	} else {
+		newErr = eh.Handle((*state)(h.e), *h.err)
 	}
 	*h.err = newErr
-	return false
+	return newErr == nil
+}
 
+// handleScopes applies the handlers added by Handle that are recorded in
+// deferred, most recent first.
+func (h errorHandler) handleScopes(deferred []deferData) (done, hadHandler bool) {
+	for i := len(deferred) - 1; i >= 0; i-- {
+		if s, ok := deferred[i].x.(handlerScope); ok {
+			if !h.e.inDefer && s.onlyBestEffort() {
+				continue
+			}
+			hadHandler = true
+			for _, eh := range s {
+				if h.handle(eh) {
+					return true, true
+				}
+			}
+		}
+	}
+	return false, hadHandler
 }
 
-func processDeferError(e *E, err error) {
-	eh := errorHandler{e: e, err: &err}
+// processDeferError handles an error returned by a deferred function. It
+// reports whether a handler requested the function to be called again.
+func processDeferError(e *E, err error) (retry bool) {
+	e.inDefer = true
+	err, a := applyDeferHandlers(e, err)
+	e.inDefer = false
+	switch {
+	case a == Retry:
+		return true
+	case err == nil:
+		return false
+	case a == Continue:
+		e.addSoft(err)
+		return false
+	}
+	err = e.withOps(err)
+	switch {
+	case a == Replace && !e.runner.inPanic:
+		e.err = &err
+	case !e.replaced:
+		e.setErr(err)
+	}
+	if len(e.runner.hooks) > 0 {
+		e.deferErrorHooks(err)
+	}
+	return false
+}
+
+// applyDeferHandlers passes err to the handlers that apply to the most
+// recently popped deferred function. It returns nil if a handler discarded the
+// error, and the Action requested by an ActionHandler, if any.
+func applyDeferHandlers(e *E, err error) (error, Action) {
+	if e.runner.redact != nil {
+		err = e.runner.redact(err)
+	}
+	e.replaced = false
+	e.appliedDefaults = false
+	var a Action
+	eh := errorHandler{e: e, err: &err, action: &a}
 	hadHandler := false
 	// Apply handlers added by Defer methods. A zero deferred value signals that
 	// we have custom defer handler for the subsequent fields.
-	for i := len(e.deferred); i > 0 && e.deferred[i-1].f == nil; i-- {
+	i := len(e.deferred)
+	for ; i > 0 && e.deferred[i-1].f == nil; i-- {
 		hadHandler = true
 		if eh.handle(e.deferred[i-1].x.(Handler)) {
-			return
+			return err, a
 		}
 	}
-	if !hadHandler {
-		for _, h := range e.runner.defaultHandlers {
+	// Apply the handlers that were in scope when the defer was added.
+	done, hadScoped := eh.handleScopes(e.deferred[:i])
+	if done {
+		return err, a
+	}
+	if (!hadHandler && !hadScoped) || (e.runner.alwaysDefaults && !e.appliedDefaults) {
+		for _, h := range e.runner.defaults(true) {
 			if eh.handle(h) {
-				return
+				return err, a
 			}
 		}
 	}
-	if e.err == nil {
-		e.err = &err
+	return err, a
+}
+
+// defaults returns the default handlers for errors returned by deferred
+// functions, if inDefer is true, or for other errors otherwise.
+func (c *config) defaults(inDefer bool) []Handler {
+	if inDefer && c.deferHandlers != nil {
+		return c.deferHandlers
 	}
+	return c.defaultHandlers
 }
 
 func processError(e *E, err error, handlers []Handler) {
-	eh := errorHandler{e: e, err: &err}
+	handleError(e, err, handlers, false)
+}
+
+// handleError handles an error passed to Must or a similar method and causes
+// Run to return if the error persists. It reports whether a handler requested
+// a retry, which is only honored if canRetry is true.
+func handleError(e *E, err error, handlers []Handler, canRetry bool) (retry bool) {
+	if i := e.runner.intercept; i != nil {
+		// Copy the handlers to prevent them from escaping in the common case.
+		i.Must(err, append([]Handler(nil), handlers...))
+	}
+	err, a := applyHandlers(e, err, handlers)
+	switch {
+	case a == Retry && canRetry:
+		return true
+	case a == Continue:
+		if err != nil {
+			e.addSoft(err)
+		}
+		return false
+	case err == nil:
+		return false
+	}
+	fail(e, err, a)
+	return false
+}
+
+// applyHandlers passes err to the given handlers, those added by Handle, and
+// the default handlers if there are no other handlers. It returns nil if a
+// handler discarded the error, and the Action requested by an ActionHandler,
+// if any.
+func applyHandlers(e *E, err error, handlers []Handler) (error, Action) {
+	if e.runner.redact != nil {
+		err = e.runner.redact(err)
+	}
+	e.replaced = false
+	e.appliedDefaults = false
+	var a Action
+	eh := errorHandler{e: e, err: &err, action: &a}
 	for _, h := range handlers {
 		if eh.handle(h) {
-			return
+			return err, a
 		}
 	}
-	if len(handlers) == 0 {
+	done, hadScoped := eh.handleScopes(e.deferred)
+	if done {
+		return err, a
+	}
+	noHandlers := (len(handlers) == 0 || handlerScope(handlers).onlyBestEffort()) && !hadScoped
+	if noHandlers || (e.runner.alwaysDefaults && !e.appliedDefaults) {
 		for _, h := range e.runner.defaultHandlers {
 			if eh.handle(h) {
-				return
+				return err, a
 			}
 		}
 	}
-	if e.err == nil {
+	return err, a
+}
+
+// fail records err, as requested by action a, and causes Run to return.
+func fail(e *E, err error, a Action) {
+	if e.runner.callSite {
+		err = withCallSite(err)
+	}
+	err = e.withOps(err)
+	switch {
+	case a == Replace:
 		e.err = &err
+	case !e.replaced:
+		e.setErr(err)
+	}
+	if len(e.runner.hooks) > 0 {
+		e.mustFailureHooks(err)
 	}
 	bail(e)
 }
 
+// addSoft records err as a soft error.
+func (e *E) addSoft(err error) {
+	if e.runner.callSite {
+		err = withCallSite(err)
+	}
+	e.soft = append(e.soft, e.withOps(err))
+}
+
 func bail(e *E) {
 	// Do defers now and save an extra defer.
 	doDefers(e, 0)
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -435,6 +436,9 @@ var testAllocsDefer = []benchCase{
 }
 
 func TestAlloc(t *testing.T) {
+	if debugDefault {
+		t.Skip("Debug allocates")
+	}
 	allFuncs := append(testFuncsNoDefer, testAllocsDefer...)
 	for _, tf := range allFuncs {
 		for _, bc := range benchCases {
@@ -556,3 +560,418 @@ func TestPanic(t *testing.T) {
 		})
 	}
 }
+
+func TestNestedRun(t *testing.T) {
+	errFoo := errors.New("foo")
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	var result string
+	trace := func(s string) Handler {
+		return HandlerFunc(func(st State, err error) error {
+			result += ":" + s
+			if st.Context().Value(key{}) != "value" {
+				result += ":nocontext"
+			}
+			return err
+		})
+	}
+	ec := WithDefault(trace("default"))
+
+	testCases := []struct {
+		desc string
+		f    func(e *E)
+		want string
+		err  error
+	}{{
+		desc: "own defers",
+		f: func(e *E) {
+			e.Defer(func() { result += ":outer" })
+			e.Run(func(e *E) {
+				e.Defer(func() { result += ":inner" })
+			})
+			result += ":after"
+		},
+		want: ":inner:after:outer",
+	}, {
+		desc: "Run propagates once",
+		f: func(e *E) {
+			e.Run(func(e *E) {
+				e.Must(errFoo)
+			})
+			result += ":unreachable"
+		},
+		want: ":default",
+		err:  errFoo,
+	}, {
+		desc: "inherit handlers",
+		f: func(e *E) {
+			e.Handle(trace("outer"))
+			e.Run(func(e *E) {
+				e.Handle(trace("inner"))
+				e.Must(errFoo)
+			})
+		},
+		want: ":inner:outer",
+		err:  errFoo,
+	}, {
+		desc: "Try",
+		f: func(e *E) {
+			e.Defer(func() { result += ":outer" })
+			err := e.Try(func(e *E) {
+				e.Defer(func() { result += ":inner" })
+				e.Must(errFoo)
+			})
+			if err == errFoo {
+				result += ":returned"
+			}
+		},
+		want: ":default:inner:returned:outer",
+	}, {
+		desc: "Try success",
+		f: func(e *E) {
+			if err := e.Try(func(e *E) {}); err == nil {
+				result += ":nil"
+			}
+		},
+		want: ":nil",
+	}, {
+		desc: "handlers stay in nested scope",
+		f: func(e *E) {
+			e.Try(func(e *E) {
+				e.Handle(trace("inner"))
+			})
+			e.Must(errFoo)
+		},
+		want: ":default",
+		err:  errFoo,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result = ""
+			err := ec.RunWithContext(ctx, tc.f)
+			if err != tc.err {
+				t.Errorf("err: got %v; want %v", err, tc.err)
+			}
+			if result != tc.want {
+				t.Errorf("result: got %q; want %q", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNestedPanic(t *testing.T) {
+	var result string
+	func() {
+		defer func() {
+			if r := recover(); r != "bar" {
+				t.Errorf("got %v; want bar", r)
+			}
+		}()
+		Run(func(e *E) {
+			e.Defer(func(err error) { result += ":outer:" + err.Error() })
+			e.Try(func(e *E) {
+				e.Defer(func(err error) { result += ":inner:" + err.Error() })
+				panic("bar")
+			})
+		})
+	}()
+	if want := ":inner:errd: paniced: bar:outer:errd: paniced: bar"; result != want {
+		t.Errorf("got %q; want %q", result, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	errFoo := errors.New("foo")
+	errBar := errors.New("bar")
+	var soft []error
+	keepSoft := func(s State) error {
+		soft = s.SoftErrors()
+		return nil
+	}
+	testCases := []struct {
+		desc string
+		f    func(e *E)
+		want string
+		soft int
+	}{{
+		desc: "no errors",
+		f: func(e *E) {
+			if !e.Check(nil) {
+				t.Error("Check(nil) returned false")
+			}
+		},
+		want: "<nil>",
+	}, {
+		desc: "one soft error",
+		f: func(e *E) {
+			if e.Check(errFoo) {
+				t.Error("Check(errFoo) returned true")
+			}
+		},
+		want: "foo",
+		soft: 1,
+	}, {
+		desc: "soft and hard",
+		f: func(e *E) {
+			e.Check(errFoo)
+			e.Check(errBar)
+			e.Must(err1)
+		},
+		want: "foo\nbar\n1",
+		soft: 2,
+	}, {
+		desc: "handled",
+		f: func(e *E) {
+			e.Check(err1, inc)
+			if !e.Check(errFoo, Discard) {
+				t.Error("discarded error not reported as passed")
+			}
+		},
+		want: "2",
+		soft: 1,
+	}, {
+		desc: "soft and defer error",
+		f: func(e *E) {
+			e.Defer(func() error { return errBar })
+			e.Check(errFoo)
+		},
+		want: "foo\nbar",
+		soft: 1,
+	}, {
+		desc: "nested soft error",
+		f: func(e *E) {
+			e.Run(func(e *E) { e.Check(errFoo) })
+			e.Check(errBar)
+		},
+		want: "foo\nbar",
+		soft: 2,
+	}, {
+		desc: "nested soft and hard",
+		f: func(e *E) {
+			e.Run(func(e *E) {
+				e.Check(errFoo)
+				e.Must(err1)
+			})
+			t.Error("nested failure did not fail e")
+		},
+		want: "foo\n1",
+		soft: 1,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			soft = nil
+			err := Run(func(e *E) {
+				e.Defer(keepSoft)
+				tc.f(e)
+			})
+			if got := fmt.Sprint(err); got != tc.want {
+				t.Errorf("err: got %q; want %q", got, tc.want)
+			}
+			if len(soft) != tc.soft {
+				t.Errorf("soft errors: got %d; want %d", len(soft), tc.soft)
+			}
+		})
+	}
+
+	err := Run(func(e *E) {
+		e.Check(errFoo)
+		e.Must(errBar)
+	})
+	if !errors.Is(err, errFoo) || !errors.Is(err, errBar) {
+		t.Errorf("got %v; want error matching both %v and %v", err, errFoo, errBar)
+	}
+}
+
+func TestFail(t *testing.T) {
+	errFoo := errors.New("foo")
+	testCases := []struct {
+		desc string
+		f    func(e *E)
+		want string
+	}{{
+		desc: "Fail",
+		f:    func(e *E) { e.Fail(errFoo) },
+		want: "foo",
+	}, {
+		desc: "Failf",
+		f:    func(e *E) { e.Failf("bad value %d", 3) },
+		want: "bad value 3",
+	}, {
+		desc: "Assert true",
+		f:    func(e *E) { e.Assert(true, "not reached") },
+		want: "<nil>",
+	}, {
+		desc: "Assert false",
+		f:    func(e *E) { e.Assert(1 > 2, "%d > %d", 1, 2) },
+		want: "1 > 2",
+	}, {
+		desc: "scoped handler",
+		f: func(e *E) {
+			e.Handle(inc)
+			e.Fail(err1)
+		},
+		want: "2",
+	}, {
+		desc: "discarded",
+		f: func(e *E) {
+			e.Handle(Discard)
+			e.Failf("foo")
+		},
+		want: "<nil>",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := Run(tc.f)
+			if got := fmt.Sprint(err); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+
+	defer func() {
+		if r := recover(); r != errNilError {
+			t.Errorf("got panic %v; want %v", r, errNilError)
+		}
+	}()
+	Run(func(e *E) { e.Fail(nil) })
+}
+
+func TestCallSites(t *testing.T) {
+	ec := WithDefault().With(CallSites)
+	errFoo := errors.New("foo")
+	var line int
+	testCases := []struct {
+		desc string
+		f    func(e *E)
+	}{{
+		desc: "Must",
+		f: func(e *E) {
+			_, _, line, _ = runtime.Caller(0)
+			e.Must(errFoo)
+		},
+	}, {
+		desc: "Fail",
+		f: func(e *E) {
+			_, _, line, _ = runtime.Caller(0)
+			e.Fail(errFoo)
+		},
+	}, {
+		desc: "Assert",
+		f: func(e *E) {
+			_, _, line, _ = runtime.Caller(0)
+			e.Assert(false, "foo")
+		},
+	}, {
+		desc: "Check",
+		f: func(e *E) {
+			_, _, line, _ = runtime.Caller(0)
+			e.Check(errFoo)
+		},
+	}, {
+		desc: "nested",
+		f: func(e *E) {
+			e.Run(func(e *E) {
+				_, _, line, _ = runtime.Caller(0)
+				e.Must(errFoo)
+			})
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ec.Run(tc.f)
+			want := fmt.Sprintf("errd_test.go:%d", line+1)
+			if got := CallSite(err); !strings.HasSuffix(got, want) {
+				t.Errorf("got %q; want suffix %q", got, want)
+			}
+			if err.Error() != "foo" {
+				t.Errorf("message: got %q; want %q", err, "foo")
+			}
+		})
+	}
+
+	if got := CallSite(Run(func(e *E) { e.Must(errFoo) })); got != "" {
+		t.Errorf("got %q; want no call site without option", got)
+	}
+}
+
+func TestReturn(t *testing.T) {
+	errFoo := errors.New("foo")
+	testCases := []struct {
+		desc   string
+		f      func(e *E)
+		want   string
+		deferd string // error observed by the deferred function
+	}{{
+		desc:   "return",
+		f:      func(e *E) { e.Return() },
+		want:   "<nil>",
+		deferd: "<nil>",
+	}, {
+		desc: "helper",
+		f: func(e *E) {
+			func(e *E) { e.Return() }(e)
+			t.Error("not returned")
+		},
+		want:   "<nil>",
+		deferd: "<nil>",
+	}, {
+		desc: "nested",
+		f: func(e *E) {
+			e.Run(func(e *E) { e.Return() })
+			e.Must(errFoo)
+		},
+		want:   "foo",
+		deferd: "foo",
+	}, {
+		desc: "soft error",
+		f: func(e *E) {
+			e.Check(errFoo)
+			e.Return()
+		},
+		want:   "foo",
+		deferd: "<nil>",
+	}, {
+		desc: "defer error",
+		f: func(e *E) {
+			e.Defer(func() error { return errFoo })
+			e.Return()
+		},
+		want:   "foo",
+		deferd: "foo",
+	}, {
+		desc: "scope",
+		f: func(e *E) {
+			e.DeferScope(func() {
+				e.Return()
+			})
+		},
+		want:   "<nil>",
+		deferd: "<nil>",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			deferd := "not called"
+			err := Run(func(e *E) {
+				e.Defer(func(err error) { deferd = fmt.Sprint(err) })
+				tc.f(e)
+			})
+			if got := fmt.Sprint(err); got != tc.want {
+				t.Errorf("err: got %q; want %q", got, tc.want)
+			}
+			if deferd != tc.deferd {
+				t.Errorf("deferred: got %q; want %q", deferd, tc.deferd)
+			}
+		})
+	}
+}
+
+func TestReturnTry(t *testing.T) {
+	v, err := Try(func(e *E) int {
+		e.Return()
+		return 1
+	})
+	if v != 0 || err != nil {
+		t.Errorf("got %v, %v; want 0, <nil>", v, err)
+	}
+}
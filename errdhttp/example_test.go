@@ -0,0 +1,33 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdhttp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/mpvl/errd"
+	"github.com/mpvl/errd/errdhttp"
+)
+
+func ExampleConfig_Handler() {
+	c := &errdhttp.Config{
+		Mappings: []errdhttp.Mapping{{Err: os.ErrNotExist, Status: http.StatusNotFound}},
+	}
+	h := c.Handler(func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open("/does/not/exist")
+		e.Must(err)
+		e.Defer(f.Close)
+		// ...
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	fmt.Print(w.Code, " ", w.Body)
+	// Output:
+	// 404 {"type":"about:blank","title":"Not Found","status":404}
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdhttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/mpvl/errd"
+)
+
+// A Mapping maps errors matching Err, as reported by errors.Is, to an HTTP
+// status code.
+type Mapping struct {
+	Err    error
+	Status int
+}
+
+// A PublicError is an error with a message that may be included in a
+// response, as opposed to the message returned by its Error method, which may
+// expose internal details like file paths.
+type PublicError interface {
+	PublicMessage() string
+}
+
+// A Config configures handlers created with its Handler method.
+type Config struct {
+	// Runner is used to run handlers. If nil, errd.Default is used.
+	Runner *errd.Runner
+
+	// Mappings determines the status of errors that do not implement
+	// StatusCoder. The first matching mapping is used. Errors that match
+	// none of them are reported with http.StatusInternalServerError.
+	Mappings []Mapping
+
+	// OnError, if non-nil, is called for each error returned by a handler or
+	// resulting from a panic, for instance to log it, before the response is
+	// written.
+	OnError func(r *http.Request, err error, status int)
+}
+
+// Handler returns an http.Handler that calls f within a call to Run, using the
+// context of the request. It is equivalent to (&Config{}).Handler(f).
+func Handler(f func(e *errd.E, w http.ResponseWriter, r *http.Request)) http.Handler {
+	return (&Config{}).Handler(f)
+}
+
+// Handler returns an http.Handler that calls f within a call to Run, using the
+// context of the request.
+//
+// If Run returns an error, the handler responds with problem details, as
+// written by WriteProblem, with the status determined by the error and c. The
+// response only includes a detail if the error has a PublicError in its
+// chain, in which case the detail is its public message. A panic in f results
+// in a response with status http.StatusInternalServerError, except for a
+// panic with http.ErrAbortHandler, which is passed on. No response is written
+// if f already started writing one, flushed, or hijacked the connection. The
+// http.ResponseWriter passed to f implements http.Flusher and http.Hijacker.
+func (c *Config) Handler(f func(e *errd.E, w http.ResponseWriter, r *http.Request)) http.Handler {
+	runner := c.Runner
+	if runner == nil {
+		runner = errd.Default
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			if x := recover(); x != nil {
+				if x == http.ErrAbortHandler {
+					panic(x)
+				}
+				err, ok := x.(error)
+				if !ok {
+					err = fmt.Errorf("errdhttp: panic: %v", x)
+				}
+				c.writeError(rw, r, err, http.StatusInternalServerError)
+			}
+		}()
+		err := runner.RunWithContext(r.Context(), func(e *errd.E) {
+			f(e, rw, r)
+		})
+		if err != nil {
+			c.writeError(rw, r, err, c.status(err))
+		}
+	})
+}
+
+// status returns the status code for err.
+func (c *Config) status(err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	for _, m := range c.Mappings {
+		if errors.Is(err, m.Err) {
+			return m.Status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func (c *Config) writeError(w *responseWriter, r *http.Request, err error, status int) {
+	if c.OnError != nil {
+		c.OnError(r, err, status)
+	}
+	if w.written {
+		return
+	}
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	var pe PublicError
+	if errors.As(err, &pe) {
+		p.Detail = pe.PublicMessage()
+	}
+	WriteProblem(w, p)
+}
+
+// A responseWriter records whether a response was started.
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher. It is a no-op if the underlying writer does
+// not support flushing.
+func (w *responseWriter) Flush() {
+	if http.NewResponseController(w.ResponseWriter).Flush() == nil {
+		w.written = true
+	}
+}
+
+// Hijack implements http.Hijacker. It returns an error matching
+// http.ErrNotSupported if the underlying writer does not support hijacking.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.written = true
+	}
+	return c, rw, err
+}
+
+// Unwrap allows http.ResponseController to access the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mpvl/errd"
+)
+
+func TestHandler(t *testing.T) {
+	errSecret := errors.New("password=hunter2")
+	var reported []error
+	c := &Config{
+		Mappings: []Mapping{
+			{os.ErrNotExist, http.StatusNotFound},
+			{io.EOF, http.StatusBadRequest},
+		},
+		OnError: func(r *http.Request, err error, status int) {
+			reported = append(reported, err)
+		},
+	}
+	testCases := []struct {
+		desc     string
+		f        func(e *errd.E, w http.ResponseWriter, r *http.Request)
+		status   int
+		body     string
+		reported int
+	}{{
+		desc: "success",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		},
+		status: 200,
+		body:   "ok",
+	}, {
+		desc: "status coder",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			e.Must(codeError(409))
+		},
+		status:   409,
+		body:     `{"type":"about:blank","title":"Conflict","status":409}` + "\n",
+		reported: 1,
+	}, {
+		desc: "mapping",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			_, err := os.Open("/does/not/exist")
+			e.Must(err)
+		},
+		status:   404,
+		body:     `{"type":"about:blank","title":"Not Found","status":404}` + "\n",
+		reported: 1,
+	}, {
+		desc: "public message",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			e.Must(fmt.Errorf("open /srv/data: %w", publicError("no such item")))
+		},
+		status:   500,
+		body:     `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"no such item"}` + "\n",
+		reported: 1,
+	}, {
+		desc: "hide server error detail",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			e.Must(errSecret)
+		},
+		status:   500,
+		body:     `{"type":"about:blank","title":"Internal Server Error","status":500}` + "\n",
+		reported: 1,
+	}, {
+		desc: "panic",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			panic("password=hunter2")
+		},
+		status:   500,
+		body:     `{"type":"about:blank","title":"Internal Server Error","status":500}` + "\n",
+		reported: 1,
+	}, {
+		desc: "response started",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			e.Must(io.EOF)
+		},
+		status:   202,
+		reported: 1,
+	}, {
+		desc: "context",
+		f: func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+			e.Defer(func(s errd.State) error {
+				if s.Context() != r.Context() {
+					t.Error("scope does not use request context")
+				}
+				return nil
+			})
+		},
+		status: 200,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			reported = nil
+			w := httptest.NewRecorder()
+			c.Handler(tc.f).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tc.status {
+				t.Errorf("status: got %d; want %d", w.Code, tc.status)
+			}
+			if got := w.Body.String(); got != tc.body {
+				t.Errorf("body: got %s; want %s", got, tc.body)
+			}
+			if len(reported) != tc.reported {
+				t.Errorf("reported: got %v; want %d errors", reported, tc.reported)
+			}
+		})
+	}
+}
+
+type publicError string
+
+func (e publicError) Error() string         { return "internal: " + string(e) }
+func (e publicError) PublicMessage() string { return string(e) }
+
+func TestHandlerAbort(t *testing.T) {
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("got panic %v; want %v", r, http.ErrAbortHandler)
+		}
+	}()
+	h := Handler(func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	r := httptest.NewRequest("GET", "/", nil).WithContext(context.Background())
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestHandlerFlush(t *testing.T) {
+	h := Handler(func(e *errd.E, w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Hijack: got %v; want %v", err, http.ErrNotSupported)
+		}
+		e.Must(io.EOF)
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.Flushed {
+		t.Error("response was not flushed")
+	}
+	if got, want := w.Body.String(), "partial"; got != want {
+		t.Errorf("body: got %q; want %q", got, want)
+	}
+}
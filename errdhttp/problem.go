@@ -0,0 +1,93 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package errdhttp provides helpers for reporting errors from errd in HTTP
+// responses.
+package errdhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// A StatusCoder is an error that determines the HTTP status code with which
+// it is reported. Package status provides such errors.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// StatusCode returns the status code of the first error in the chain of err
+// that implements StatusCoder, or http.StatusInternalServerError if there is
+// none. It returns http.StatusOK for a nil error.
+func StatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// A Problem holds the problem details of RFC 7807.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Error is an extension member holding a representation of the error,
+	// for instance as returned by errd.MarshalJSON. It is not set by
+	// NewProblem, as it may expose internal details.
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// NewProblem returns the problem details for err. The status is determined
+// by StatusCode and the title is the corresponding status text. The detail is
+// the public message of the first PublicError in the chain of err, if any.
+// Otherwise, it is the error message for client errors, which have a status
+// below 500, and empty for server errors, as their message may expose
+// internal details.
+func NewProblem(err error) *Problem {
+	status := StatusCode(err)
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	var pe PublicError
+	switch {
+	case err == nil:
+	case errors.As(err, &pe):
+		p.Detail = pe.PublicMessage()
+	case status < 500:
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// WriteProblem writes p as a response of type application/problem+json with
+// the status of p.
+func WriteProblem(w http.ResponseWriter, p *Problem) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("Content-Length", strconv.Itoa(len(b)+1))
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// WriteError writes the problem details for err, as returned by NewProblem.
+func WriteError(w http.ResponseWriter, err error) error {
+	return WriteProblem(w, NewProblem(err))
+}
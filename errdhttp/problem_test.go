@@ -0,0 +1,84 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mpvl/errd"
+)
+
+type codeError int
+
+func (c codeError) Error() string   { return fmt.Sprintf("code %d", int(c)) }
+func (c codeError) StatusCode() int { return int(c) }
+
+func TestWriteError(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		err    error
+		status int
+		body   string
+	}{{
+		desc:   "plain",
+		err:    errors.New("foo"),
+		status: 500,
+		body:   `{"type":"about:blank","title":"Internal Server Error","status":500}`,
+	}, {
+		desc:   "public message",
+		err:    fmt.Errorf("open /srv/data: %w", publicError("no such item")),
+		status: 500,
+		body:   `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"no such item"}`,
+	}, {
+		desc:   "status",
+		err:    fmt.Errorf("bar: %w", codeError(404)),
+		status: 404,
+		body:   `{"type":"about:blank","title":"Not Found","status":404,"detail":"bar: code 404"}`,
+	}, {
+		desc: "errd",
+		err: errd.WithDefault().With(errd.Named("op")).Run(func(e *errd.E) {
+			e.Must(codeError(400))
+		}),
+		status: 400,
+		body:   `{"type":"about:blank","title":"Bad Request","status":400,"detail":"op: code 400"}`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WriteError(w, tc.err); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.status {
+				t.Errorf("status: got %d; want %d", w.Code, tc.status)
+			}
+			if got, want := w.Header().Get("Content-Type"), "application/problem+json"; got != want {
+				t.Errorf("content type: got %q; want %q", got, want)
+			}
+			if got := w.Body.String(); got != tc.body+"\n" {
+				t.Errorf("body: got %s; want %s", got, tc.body)
+			}
+		})
+	}
+}
+
+func TestProblemError(t *testing.T) {
+	err := errors.New("foo")
+	p := NewProblem(err)
+	p.Error, _ = errd.MarshalJSON(err)
+	w := httptest.NewRecorder()
+	WriteProblem(w, p)
+	want := `{"type":"about:blank","title":"Internal Server Error","status":500,` +
+		`"error":{"message":"foo","type":"*errors.errorString"}}` + "\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got %s; want %s", got, want)
+	}
+	if got := StatusCode(nil); got != http.StatusOK {
+		t.Errorf("StatusCode(nil): got %d; want %d", got, http.StatusOK)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package errdtest provides utilities for testing code that uses package errd.
+//
+// Helpers that take an *errd.E, like a function that creates a context and
+// defers its cancel function, are hard to test on their own: the defers they
+// add run as soon as the enclosing Run returns. A Recorder runs such helpers
+// and records the calls they make, holding back the defers until a test
+// chooses to run them.
+//
+// A SpanRecorder records the spans created for a Runner with errd.Trace.
+package errdtest
+
+import (
+	"github.com/mpvl/errd"
+	"github.com/mpvl/errd/internal/hook"
+)
+
+// A Kind indicates which method of errd.E was called.
+type Kind int
+
+const (
+	// Must indicates a call to Must.
+	Must Kind = iota
+
+	// Defer indicates a call to Defer.
+	Defer
+
+	// Check indicates a call to Check.
+	Check
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Must:
+		return "Must"
+	case Defer:
+		return "Defer"
+	case Check:
+		return "Check"
+	}
+	return "Kind(?)"
+}
+
+// A Call is a recorded call to a method of errd.E.
+type Call struct {
+	Kind Kind
+
+	// Err is the error passed to Must or Check.
+	Err error
+
+	// Value is the value passed to Defer.
+	Value interface{}
+
+	// Handlers holds the handlers passed with the call.
+	Handlers []errd.Handler
+
+	// Scoped holds the handlers added by Handle that were in scope for a
+	// call to Defer, in the order in which they apply.
+	Scoped []errd.Handler
+}
+
+// A Recorder records the calls to Must, Check, and Defer made on the E passed
+// by Run. Only calls to Must and Check with a non-nil error are recorded, as
+// they are no-ops otherwise.
+//
+// A Must or Check call is handled as usual after it is recorded. Deferred
+// values are not run when Run returns, but when RunDefers is called.
+type Recorder struct {
+	// Runner is used to run functions and defers. errd.Default is used if
+	// Runner is nil.
+	Runner *errd.Runner
+
+	// Calls holds all recorded calls in the order in which they were made.
+	Calls []Call
+
+	pending []Call
+}
+
+func (r *Recorder) runner() *errd.Runner {
+	if r.Runner == nil {
+		return errd.Default
+	}
+	return r.Runner
+}
+
+// Run calls f with an E that records calls in r and returns the error
+// returned by the underlying errd.Runner.
+func (r *Recorder) Run(f func(e *errd.E)) error {
+	runner := hook.Intercept(r.runner(), (*interceptor)(r)).(*errd.Runner)
+	return runner.Run(f)
+}
+
+// Defers reports the deferred calls that have not yet been run, in the order
+// in which they were added.
+func (r *Recorder) Defers() []Call {
+	return r.pending
+}
+
+// RunDefers runs all pending defers in the reverse order in which they were
+// added, using the handlers that were passed with them, followed by the
+// handlers that were in scope. It returns the resulting error, if any. Labels
+// and fields added with Op and With are not applied.
+func (r *Recorder) RunDefers() error {
+	pending := r.pending
+	r.pending = nil
+	return r.runner().Run(func(e *errd.E) {
+		for _, c := range pending {
+			h := append(c.Handlers[:len(c.Handlers):len(c.Handlers)], c.Scoped...)
+			e.Defer(c.Value, h...)
+		}
+	})
+}
+
+type interceptor Recorder
+
+func (r *interceptor) Must(err error, h interface{}) {
+	r.Calls = append(r.Calls, Call{
+		Kind:     Must,
+		Err:      err,
+		Handlers: h.([]errd.Handler),
+	})
+}
+
+func (r *interceptor) Check(err error, h interface{}) {
+	r.Calls = append(r.Calls, Call{
+		Kind:     Check,
+		Err:      err,
+		Handlers: h.([]errd.Handler),
+	})
+}
+
+func (r *interceptor) Defer(x interface{}, h, scoped interface{}) {
+	c := Call{
+		Kind:     Defer,
+		Value:    x,
+		Handlers: h.([]errd.Handler),
+		Scoped:   scoped.([]errd.Handler),
+	}
+	r.Calls = append(r.Calls, c)
+	r.pending = append(r.pending, c)
+}
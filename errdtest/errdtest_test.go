@@ -0,0 +1,141 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mpvl/errd"
+)
+
+func TestRecorder(t *testing.T) {
+	var result string
+	errMust := errors.New("must")
+	errDefer := errors.New("defer")
+
+	h := errd.HandlerFunc(func(s errd.State, err error) error {
+		result += ":handled"
+		return err
+	})
+
+	r := &Recorder{}
+	err := r.Run(func(e *errd.E) {
+		e.Must(nil)
+		e.Defer(func() { result += ":first" })
+		e.Defer(func() error { return errDefer }, h)
+		e.Must(errMust, h)
+		result += ":unreachable"
+	})
+	if err != errMust {
+		t.Errorf("err: got %v; want %v", err, errMust)
+	}
+	if result != ":handled" {
+		t.Errorf("result: got %q; want %q", result, ":handled")
+	}
+
+	kinds := []Kind{Defer, Defer, Must}
+	if len(r.Calls) != len(kinds) {
+		t.Fatalf("got %d calls; want %d", len(r.Calls), len(kinds))
+	}
+	for i, k := range kinds {
+		if c := r.Calls[i]; c.Kind != k {
+			t.Errorf("%d: got %v; want %v", i, c.Kind, k)
+		}
+	}
+	if c := r.Calls[2]; c.Err != errMust || len(c.Handlers) != 1 {
+		t.Errorf("Must: got %v with %d handlers; want %v with 1 handler", c.Err, len(c.Handlers), errMust)
+	}
+	if n := len(r.Defers()); n != 2 {
+		t.Fatalf("got %d pending defers; want 2", n)
+	}
+
+	result = ""
+	if err := r.RunDefers(); err != errDefer {
+		t.Errorf("RunDefers: got %v; want %v", err, errDefer)
+	}
+	if want := ":handled:first"; result != want {
+		t.Errorf("result: got %q; want %q", result, want)
+	}
+	if n := len(r.Defers()); n != 0 {
+		t.Errorf("got %d pending defers after RunDefers; want 0", n)
+	}
+}
+
+func TestRecorderCheck(t *testing.T) {
+	errCheck := errors.New("check")
+	r := &Recorder{}
+	err := r.Run(func(e *errd.E) {
+		e.Check(nil)
+		if !e.Check(errCheck, errd.Discard) {
+			t.Error("Check: got false for discarded error; want true")
+		}
+		e.Check(errCheck)
+	})
+	if err == nil {
+		t.Error("Run: got nil; want soft error")
+	}
+	if len(r.Calls) != 2 {
+		t.Fatalf("got %d calls; want 2", len(r.Calls))
+	}
+	for i, c := range r.Calls {
+		if c.Kind != Check || c.Err != errCheck {
+			t.Errorf("%d: got %v(%v); want Check(%v)", i, c.Kind, c.Err, errCheck)
+		}
+	}
+	if n := len(r.Calls[0].Handlers); n != 1 {
+		t.Errorf("got %d handlers; want 1", n)
+	}
+}
+
+func TestRecorderScoped(t *testing.T) {
+	errDefer := errors.New("defer")
+	var handled int
+	h := errd.HandlerFunc(func(s errd.State, err error) error {
+		handled++
+		return nil
+	})
+	r := &Recorder{Runner: errd.WithDefault(errd.HandlerFunc(func(s errd.State, err error) error {
+		t.Errorf("default handler applied to %v", err)
+		return err
+	}))}
+	r.Run(func(e *errd.E) {
+		e.Handle(h)
+		e.Defer(func() error { return errDefer })
+	})
+	if n := len(r.Calls[0].Scoped); n != 1 {
+		t.Errorf("got %d scoped handlers; want 1", n)
+	}
+	if err := r.RunDefers(); err != nil {
+		t.Errorf("RunDefers: got %v; want nil", err)
+	}
+	if handled != 1 {
+		t.Errorf("scoped handler called %d times; want 1", handled)
+	}
+}
+
+func TestRecorderRunner(t *testing.T) {
+	r := &Recorder{Runner: errd.WithDefault(errd.Discard)}
+	err := r.Run(func(e *errd.E) {
+		e.Must(errors.New("discarded"))
+		e.Defer(func() error { return errors.New("discarded") })
+	})
+	if err != nil {
+		t.Errorf("Run: got %v; want nil", err)
+	}
+	if err := r.RunDefers(); err != nil {
+		t.Errorf("RunDefers: got %v; want nil", err)
+	}
+}
+
+func TestRecorderUnsupported(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for unsupported type")
+		}
+	}()
+	r := &Recorder{}
+	r.Run(func(e *errd.E) { e.Defer(1) })
+}
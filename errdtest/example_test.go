@@ -0,0 +1,38 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdtest_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mpvl/errd"
+	"github.com/mpvl/errd/errdtest"
+)
+
+func contextWithTimeout(e *errd.E, timeout time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	e.Defer(func() { cancel() })
+	return ctx
+}
+
+func ExampleRecorder() {
+	var ctx context.Context
+	r := &errdtest.Recorder{}
+	r.Run(func(e *errd.E) {
+		ctx = contextWithTimeout(e, time.Hour)
+	})
+
+	// The cancel function was recorded, but not yet called.
+	fmt.Println(len(r.Defers()), r.Defers()[0].Kind, ctx.Err())
+
+	r.RunDefers()
+	fmt.Println(ctx.Err())
+
+	// Output:
+	// 1 Defer <nil>
+	// context canceled
+}
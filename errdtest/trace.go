@@ -0,0 +1,96 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdtest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mpvl/errd"
+)
+
+// A SpanRecorder is an errd.Tracer that records spans in memory, for use in
+// tests:
+//
+//	rec := &errdtest.SpanRecorder{}
+//	ec := errd.WithDefault().With(errd.Trace(rec))
+//
+// A span started with a context that carries a recorded span is recorded as a
+// child of that span.
+type SpanRecorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// A RecordedSpan holds the data of a span recorded by a SpanRecorder.
+type RecordedSpan struct {
+	Name     string
+	Start    time.Time
+	End      time.Time // zero if the span did not end
+	Err      error
+	Events   []Event
+	Children []*RecordedSpan
+}
+
+// An Event is an event recorded for a span.
+type Event struct {
+	Name string
+	Time time.Time
+	Err  error
+}
+
+// Start implements errd.Tracer.
+func (r *SpanRecorder) Start(ctx context.Context, name string, start time.Time) (context.Context, errd.Span) {
+	if p, ok := errd.SpanFromContext(ctx).(*span); ok && p.r == r {
+		return ctx, p.Child(name, start)
+	}
+	s := &RecordedSpan{Name: name, Start: start}
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+	return ctx, &span{r, s}
+}
+
+// Spans returns the recorded root spans. The spans may not be modified while
+// they are still recorded to.
+func (r *SpanRecorder) Spans() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*RecordedSpan(nil), r.spans...)
+}
+
+// Reset discards all recorded spans.
+func (r *SpanRecorder) Reset() {
+	r.mu.Lock()
+	r.spans = nil
+	r.mu.Unlock()
+}
+
+// A span implements errd.Span for a RecordedSpan.
+type span struct {
+	r *SpanRecorder
+	s *RecordedSpan
+}
+
+func (s *span) Child(name string, start time.Time) errd.Span {
+	c := &RecordedSpan{Name: name, Start: start}
+	s.r.mu.Lock()
+	s.s.Children = append(s.s.Children, c)
+	s.r.mu.Unlock()
+	return &span{s.r, c}
+}
+
+func (s *span) Event(name string, t time.Time, err error) {
+	s.r.mu.Lock()
+	s.s.Events = append(s.s.Events, Event{name, t, err})
+	s.r.mu.Unlock()
+}
+
+func (s *span) End(t time.Time, err error) {
+	s.r.mu.Lock()
+	s.s.End, s.s.Err = t, err
+	s.r.mu.Unlock()
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errd"
+)
+
+// format returns a compact representation of s and its children.
+func format(s *RecordedSpan) string {
+	var parts []string
+	for _, e := range s.Events {
+		parts = append(parts, fmt.Sprintf("%s:%v", e.Name, e.Err))
+	}
+	for _, c := range s.Children {
+		parts = append(parts, format(c))
+	}
+	res := fmt.Sprintf("%s(%v)", s.Name, s.Err)
+	if len(parts) > 0 {
+		res += "{" + strings.Join(parts, " ") + "}"
+	}
+	return res
+}
+
+func TestSpanRecorder(t *testing.T) {
+	rec := &SpanRecorder{}
+	ec := errd.WithDefault().With(errd.Named("op"), errd.Trace(rec))
+	errFoo := errors.New("foo")
+	errBar := errors.New("bar")
+
+	testCases := []struct {
+		desc   string
+		runner *errd.Runner
+		f      func(e *errd.E)
+		want   string
+	}{{
+		desc:   "success",
+		runner: ec,
+		f:      func(e *errd.E) { e.Defer(func() {}) },
+		want:   "op(<nil>){defer(<nil>)}",
+	}, {
+		desc:   "unnamed",
+		runner: errd.WithDefault().With(errd.Trace(rec)),
+		f:      func(e *errd.E) {},
+		want:   "errd.Run(<nil>)",
+	}, {
+		desc:   "failure",
+		runner: ec,
+		f: func(e *errd.E) {
+			e.Defer(func() error { return errBar })
+			e.Defer(func() {})
+			e.Must(errFoo)
+		},
+		want: "op(op: foo){must:op: foo defer(<nil>) defer(bar)}",
+	}, {
+		desc:   "nested scope",
+		runner: ec,
+		f: func(e *errd.E) {
+			e.Try(func(e *errd.E) { e.Defer(func() {}) })
+		},
+		want: "op(<nil>){defer(<nil>)}",
+	}, {
+		desc:   "nested run",
+		runner: ec,
+		f: func(e *errd.E) {
+			e.Defer(func(s errd.State) error {
+				return ec.RunWithContext(s.Context(), func(e *errd.E) { e.Must(errFoo) })
+			})
+		},
+		want: "op(op: foo){op(op: foo){must:op: foo} defer(op: foo)}",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			rec.Reset()
+			tc.runner.Run(tc.f)
+			spans := rec.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans; want 1", len(spans))
+			}
+			if got := format(spans[0]); got != tc.want {
+				t.Errorf("got %s; want %s", got, tc.want)
+			}
+			if s := spans[0]; s.End.Before(s.Start) {
+				t.Errorf("span ends before it starts")
+			}
+		})
+	}
+}
@@ -20,10 +20,82 @@ var (
 
 	// Fatal is handler that causes execution to halt.
 	Fatal Handler = HandlerFunc(fatal)
+
+	// BestEffort is a handler that marks an error as non-essential, as is
+	// typically the case for errors from closing read-only resources. The
+	// error is passed to the handlers set with the BestEffortHandlers option,
+	// for instance to log it, and is then discarded:
+	//
+	//	e.Defer(r.Close, errd.BestEffort)
+	//
+	// Only errors returned by deferred functions are affected; other errors
+	// are passed on unchanged, and BestEffort does not count as a handler
+	// for them when determining whether to apply the default handlers.
+	// Handle(errd.BestEffort) thus marks all subsequent defers of a scope as
+	// best-effort.
+	BestEffort Handler = bestEffortHandler{}
+
+	// AndDefaults is a handler that applies the default handlers of the
+	// Runner at its position in a handler chain. Normally, the default
+	// handlers are not applied if there are any other handlers:
+	//
+	//	e.Must(err, msg("reading config"), errd.AndDefaults)
+	//
+	// For errors returned by deferred functions, AndDefaults applies the
+	// handlers set with DeferHandlers, if any.
+	AndDefaults Handler = ActionFunc(andDefaults)
 )
 
 func discard(s State, err error) error { return nil }
 
+// andDefaults applies the default handlers, passing on the Action of any
+// ActionHandler among them. bestEffort does the same for its handlers.
+func andDefaults(s State, err error) (Action, error) {
+	var a Action
+	if st, ok := s.(*state); ok {
+		st.appliedDefaults = true
+		eh := errorHandler{e: (*E)(st), err: &err, action: &a}
+		for _, h := range st.runner.defaults(st.inDefer) {
+			if eh.handle(h) {
+				break
+			}
+		}
+	}
+	return a, err
+}
+
+// bestEffortHandler is the type of BestEffort, which allows it to be
+// recognized in a list of handlers.
+type bestEffortHandler struct{}
+
+func (bestEffortHandler) Handle(s State, err error) error {
+	_, err = bestEffort(s, err)
+	return err
+}
+
+func (bestEffortHandler) HandleAction(s State, err error) (Action, error) {
+	return bestEffort(s, err)
+}
+
+func bestEffort(s State, err error) (Action, error) {
+	if !s.InDefer() {
+		return Next, err
+	}
+	var a Action
+	if st, ok := s.(*state); ok {
+		eh := errorHandler{e: (*E)(st), err: &err, action: &a}
+		for _, h := range st.runner.bestEffort {
+			if eh.handle(h) {
+				break
+			}
+		}
+	}
+	if a != Next {
+		return a, err
+	}
+	return Next, nil
+}
+
 func fatal(s State, err error) error {
 	os.Exit(1)
 	return nil
@@ -38,3 +110,71 @@ type HandlerFunc func(s State, err error) error
 func (f HandlerFunc) Handle(s State, err error) error {
 	return f(s, err)
 }
+
+// DeferHandlers returns an Option that sets the default handlers for errors
+// returned by deferred functions, which otherwise are the same as those of
+// the Runner. As with the Runner's default handlers, they are only applied if
+// a deferred function has no other handlers.
+func DeferHandlers(h ...Handler) Option {
+	// Use a non-nil slice, so that passing no handlers overrides the default.
+	h = append([]Handler{}, h...)
+	return func(c *config) { c.deferHandlers = h }
+}
+
+// AlwaysDefaults is an Option that causes the default handlers of a Runner to
+// be applied after any other handlers, instead of only if there are no other
+// handlers. The default handlers are not applied if another handler discards
+// the error or if AndDefaults already applied them.
+var AlwaysDefaults Option = func(c *config) { c.alwaysDefaults = true }
+
+// BestEffortHandlers returns an Option that sets the handlers that are
+// applied by BestEffort before it discards an error.
+func BestEffortHandlers(h ...Handler) Option {
+	return func(c *config) { c.bestEffort = h }
+}
+
+// Handle adds handlers that apply to all errors passed to Must and returned by
+// deferred functions for the remainder of the scope. Handlers passed to Must
+// or Defer are applied first, followed by the handlers of the most recent call
+// to Handle, and so on. Default handlers are only applied if there are no
+// other handlers.
+//
+// Deferred functions that were added before a call to Handle are not affected
+// by it. Handlers added within DeferScope are removed when it returns.
+func (e *E) Handle(h ...Handler) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	if len(h) > 0 {
+		e.deferred = append(e.deferred, deferData{append(handlerScope(nil), h...), popScope})
+	}
+}
+
+// A handlerScope records the handlers passed to Handle in the defer stack.
+type handlerScope []Handler
+
+// scopedHandlers returns the handlers added by Handle that are in scope, in
+// the order in which they are applied.
+func (e *E) scopedHandlers() []Handler {
+	var h []Handler
+	for i := len(e.deferred) - 1; i >= 0; i-- {
+		if s, ok := e.deferred[i].x.(handlerScope); ok {
+			h = append(h, s...)
+		}
+	}
+	return h
+}
+
+// onlyBestEffort reports whether s consists of BestEffort handlers only, which
+// pass on errors not returned by deferred functions.
+func (s handlerScope) onlyBestEffort() bool {
+	for _, h := range s {
+		if _, ok := h.(bestEffortHandler); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// popScope is the deferFunc for a handlerScope, which is a no-op.
+func popScope(s State, x interface{}) error { return nil }
@@ -172,3 +172,285 @@ func BenchmarkFuncOption(b *testing.B) {
 		}
 	})
 }
+
+func TestHandle(t *testing.T) {
+	trace := func(s string, result *string) Handler {
+		return HandlerFunc(func(st State, err error) error {
+			*result += ":" + s
+			return err
+		})
+	}
+	testCases := []struct {
+		desc string
+		f    func(e *E, result *string)
+		want string
+		err  error
+	}{{
+		desc: "scoped replaces default",
+		f: func(e *E, r *string) {
+			e.Handle(trace("h1", r))
+			e.Must(err1)
+		},
+		want: ":h1",
+		err:  err1,
+	}, {
+		desc: "per-call first",
+		f: func(e *E, r *string) {
+			e.Handle(trace("h1", r), trace("h2", r))
+			e.Must(err1, trace("call", r))
+		},
+		want: ":call:h1:h2",
+		err:  err1,
+	}, {
+		desc: "most recent first",
+		f: func(e *E, r *string) {
+			e.Handle(trace("h1", r))
+			e.Handle(trace("h2", r))
+			e.Must(err1)
+		},
+		want: ":h2:h1",
+		err:  err1,
+	}, {
+		desc: "modify",
+		f: func(e *E, r *string) {
+			e.Handle(inc)
+			e.Must(err1, inc)
+		},
+		err: err3,
+	}, {
+		desc: "discard",
+		f: func(e *E, r *string) {
+			e.Handle(trace("h1", r))
+			e.Handle(Discard)
+			e.Must(err1)
+			*r += ":continued"
+		},
+		want: ":continued",
+	}, {
+		desc: "defer after Handle",
+		f: func(e *E, r *string) {
+			e.Handle(trace("h1", r))
+			e.Defer(func() error { return err1 }, trace("defer", r))
+		},
+		want: ":defer:h1",
+		err:  err1,
+	}, {
+		desc: "defer before Handle",
+		f: func(e *E, r *string) {
+			e.Defer(func() error { return err1 })
+			e.Handle(trace("h1", r))
+		},
+		want: ":default",
+		err:  err1,
+	}, {
+		desc: "DeferScope",
+		f: func(e *E, r *string) {
+			e.DeferScope(func() {
+				e.Handle(trace("h1", r))
+			})
+			e.Must(err1)
+		},
+		want: ":default",
+		err:  err1,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result := ""
+			ec := WithDefault(trace("default", &result))
+			err := ec.Run(func(e *E) {
+				tc.f(e, &result)
+			})
+			if err != tc.err {
+				t.Errorf("err: got %v; want %v", err, tc.err)
+			}
+			if result != tc.want {
+				t.Errorf("result: got %q; want %q", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeferHandlers(t *testing.T) {
+	var logged []string
+	log := HandlerFunc(func(s State, err error) error {
+		logged = append(logged, fmt.Sprintf("%v:%v", err, s.InDefer()))
+		return err
+	})
+	testCases := []struct {
+		desc   string
+		runner *Runner
+		f      func(e *E)
+		want   error
+		logged string
+	}{{
+		desc:   "defaults apply to defers",
+		runner: WithDefault(inc),
+		f:      func(e *E) { e.Defer(func() error { return err1 }) },
+		want:   err2,
+	}, {
+		desc:   "defer defaults",
+		runner: WithDefault(inc).With(DeferHandlers(dec)),
+		f: func(e *E) {
+			e.Defer(func() error { return err1 })
+			e.Must(err1)
+		},
+		want: err2,
+	}, {
+		desc:   "defer defaults only",
+		runner: WithDefault(inc).With(DeferHandlers(dec)),
+		f:      func(e *E) { e.Defer(func() error { return err1 }) },
+		want:   err0,
+	}, {
+		desc:   "no defer defaults",
+		runner: WithDefault(inc).With(DeferHandlers()),
+		f:      func(e *E) { e.Defer(func() error { return err1 }) },
+		want:   err1,
+	}, {
+		desc:   "explicit handler overrides defer defaults",
+		runner: WithDefault().With(DeferHandlers(Discard)),
+		f:      func(e *E) { e.Defer(func() error { return err1 }, inc) },
+		want:   err2,
+	}, {
+		desc:   "in defer",
+		runner: WithDefault(log),
+		f: func(e *E) {
+			e.Defer(func() error { return err2 })
+			e.Must(err1)
+		},
+		want:   err1,
+		logged: "[1:false 2:true]",
+	}, {
+		desc:   "best effort",
+		runner: WithDefault().With(BestEffortHandlers(log)),
+		f:      func(e *E) { e.Defer(func() error { return err1 }, BestEffort) },
+		want:   nil,
+		logged: "[1:true]",
+	}, {
+		desc:   "best effort without handlers",
+		runner: WithDefault(),
+		f:      func(e *E) { e.Defer(func() error { return err1 }, BestEffort) },
+		want:   nil,
+	}, {
+		desc:   "best effort scope",
+		runner: WithDefault().With(BestEffortHandlers(inc, log)),
+		f: func(e *E) {
+			e.Defer(func() error { return err3 })
+			e.Handle(BestEffort)
+			e.Defer(func() error { return err1 })
+		},
+		want:   err3,
+		logged: "[2:true]",
+	}, {
+		desc:   "best effort scope does not affect Must",
+		runner: WithDefault(inc).With(BestEffortHandlers(log)),
+		f: func(e *E) {
+			e.Handle(BestEffort)
+			e.Must(err1)
+			t.Error("Must did not fail")
+		},
+		want:   err2,
+		logged: "[]",
+	}, {
+		desc:   "best effort Must",
+		runner: WithDefault(inc).With(BestEffortHandlers(log)),
+		f:      func(e *E) { e.Must(err2, BestEffort) },
+		want:   err3,
+		logged: "[]",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			logged = nil
+			err := tc.runner.Run(tc.f)
+			if err != tc.want {
+				t.Errorf("err: got %v; want %v", err, tc.want)
+			}
+			if got := fmt.Sprint(logged); tc.logged != "" && got != tc.logged {
+				t.Errorf("logged: got %s; want %s", got, tc.logged)
+			}
+		})
+	}
+}
+
+func TestDefaultsCombined(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		runner *Runner
+		f      func(e *E)
+		want   error
+	}{{
+		desc:   "handler suppresses defaults",
+		runner: WithDefault(inc),
+		f:      func(e *E) { e.Must(err0, inc) },
+		want:   err1,
+	}, {
+		desc:   "and defaults",
+		runner: WithDefault(inc),
+		f:      func(e *E) { e.Must(err0, inc, AndDefaults) },
+		want:   err2,
+	}, {
+		desc:   "and defaults first",
+		runner: WithDefault(inc),
+		f:      func(e *E) { e.Must(err1, AndDefaults, Discard) },
+		want:   nil,
+	}, {
+		desc:   "and defaults discard",
+		runner: WithDefault(Discard),
+		f:      func(e *E) { e.Must(err1, inc, AndDefaults, inc) },
+		want:   nil,
+	}, {
+		desc:   "and defaults in scope",
+		runner: WithDefault(inc),
+		f: func(e *E) {
+			e.Handle(inc, AndDefaults)
+			e.Must(err0)
+		},
+		want: err2,
+	}, {
+		desc:   "and defaults in defer",
+		runner: WithDefault(inc).With(DeferHandlers(dec)),
+		f:      func(e *E) { e.Defer(func() error { return err1 }, inc, AndDefaults) },
+		want:   err1,
+	}, {
+		desc:   "always",
+		runner: WithDefault(inc).With(AlwaysDefaults),
+		f:      func(e *E) { e.Must(err0, inc) },
+		want:   err2,
+	}, {
+		desc:   "always without handlers",
+		runner: WithDefault(inc).With(AlwaysDefaults),
+		f:      func(e *E) { e.Must(err0) },
+		want:   err1,
+	}, {
+		desc:   "always in defer",
+		runner: WithDefault(inc).With(AlwaysDefaults),
+		f:      func(e *E) { e.Defer(func() error { return err0 }, inc) },
+		want:   err2,
+	}, {
+		desc:   "always after discard",
+		runner: WithDefault(inc).With(AlwaysDefaults),
+		f:      func(e *E) { e.Must(err0, Discard) },
+		want:   nil,
+	}, {
+		desc:   "always and defaults",
+		runner: WithDefault(inc).With(AlwaysDefaults),
+		f:      func(e *E) { e.Must(err0, AndDefaults, inc) },
+		want:   err2,
+	}, {
+		desc:   "always and defaults in defer",
+		runner: WithDefault(inc).With(AlwaysDefaults),
+		f:      func(e *E) { e.Defer(func() error { return err0 }, AndDefaults) },
+		want:   err1,
+	}, {
+		desc:   "always discard",
+		runner: WithDefault(Discard).With(AlwaysDefaults),
+		f:      func(e *E) { e.Must(err0, inc) },
+		want:   nil,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := tc.runner.Run(tc.f); err != tc.want {
+				t.Errorf("got %v; want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks define functions that are called at points in the lifecycle of a
+// Run. They allow plugging in tracing, metrics, audit logging, and the like.
+// Any of the functions may be nil.
+//
+// Hooks are called synchronously and may not use the E of the scope.
+type Hooks struct {
+	// OnStart is called when a call to Run starts, before calling its
+	// function. It returns the context to use for the scope, which may be
+	// the context reported by s.
+	OnStart func(s State) context.Context
+
+	// OnMustFailure is called when an error passed to Must or a similar
+	// method causes Run to return. It is passed the error after handling.
+	OnMustFailure func(s State, err error)
+
+	// OnDeferRun is called after a deferred function was called. It is passed
+	// the time it took and the error it returned, before handling.
+	OnDeferRun func(s State, elapsed time.Duration, err error)
+
+	// OnDeferError is called for an error returned by a deferred function
+	// that persists after handling.
+	OnDeferError func(s State, err error)
+
+	// OnPanic is called with the recovered value when a panic occurs. It is
+	// called once per panic, by the outermost scope.
+	OnPanic func(s State, r interface{})
+
+	// OnFinish is called when Run returns, with the error it returns and the
+	// time since it started. It is also called if Run returns because of a
+	// panic.
+	OnFinish func(s State, err error, elapsed time.Duration)
+}
+
+// WithHooks returns an Option that adds the given hooks to the Runner. Hooks
+// added by earlier options are called first.
+//
+// OnStart and OnFinish are only called for top-level calls to Run and
+// similar functions, not for nested scopes.
+func WithHooks(h Hooks) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks[:len(c.hooks):len(c.hooks)], &h)
+	}
+}
+
+// startHooks records the start of a top-level scope and calls the OnStart
+// hooks.
+func (e *E) startHooks() {
+	e.start = time.Now()
+	for _, h := range e.runner.hooks {
+		if h.OnStart != nil {
+			e.context = h.OnStart((*state)(e))
+		}
+	}
+}
+
+func (e *E) finishHooks(err error) {
+	elapsed := time.Since(e.start)
+	for _, h := range e.runner.hooks {
+		if h.OnFinish != nil {
+			h.OnFinish((*state)(e), err, elapsed)
+		}
+	}
+}
+
+func (e *E) mustFailureHooks(err error) {
+	for _, h := range e.runner.hooks {
+		if h.OnMustFailure != nil {
+			h.OnMustFailure((*state)(e), err)
+		}
+	}
+}
+
+func (e *E) deferErrorHooks(err error) {
+	for _, h := range e.runner.hooks {
+		if h.OnDeferError != nil {
+			h.OnDeferError((*state)(e), err)
+		}
+	}
+}
+
+func (e *E) panicHooks(r interface{}) {
+	for _, h := range e.runner.hooks {
+		if h.OnPanic != nil {
+			h.OnPanic((*state)(e), r)
+		}
+	}
+}
+
+// runDeferHooked calls the deferred function d and the OnDeferRun hooks.
+func (e *E) runDeferHooked(d deferData) error {
+	switch d.x.(type) {
+	case handlerScope, opLabel, Field:
+		return nil
+	}
+	start := time.Now()
+	err := d.f((*state)(e), d.x)
+	elapsed := time.Since(start)
+	for _, h := range e.runner.hooks {
+		if h.OnDeferRun != nil {
+			h.OnDeferRun((*state)(e), elapsed, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type hookKey struct{}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	add := func(format string, args ...interface{}) {
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+	hooks := Hooks{
+		OnStart: func(s State) context.Context {
+			add("start %s", s.Name())
+			return context.WithValue(s.Context(), hookKey{}, "x")
+		},
+		OnMustFailure: func(s State, err error) { add("must %v", err) },
+		OnDeferRun: func(s State, elapsed time.Duration, err error) {
+			if elapsed < 0 {
+				t.Errorf("negative elapsed time %v", elapsed)
+			}
+			add("defer %v", err)
+		},
+		OnDeferError: func(s State, err error) { add("defer error %v", err) },
+		OnPanic:      func(s State, r interface{}) { add("panic %v", r) },
+		OnFinish: func(s State, err error, elapsed time.Duration) {
+			add("finish %v", err)
+		},
+	}
+	ec := WithDefault().With(Named("op"), WithHooks(hooks))
+
+	testCases := []struct {
+		desc string
+		f    func(e *E)
+		want string
+	}{{
+		desc: "success",
+		f: func(e *E) {
+			e.Handle(inc)
+			e.Defer(func() {})
+			if got := (*state)(e).Context().Value(hookKey{}); got != "x" {
+				t.Errorf("context value: got %v; want x", got)
+			}
+		},
+		want: "start op; defer <nil>; finish <nil>",
+	}, {
+		desc: "must",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 }, inc)
+			e.Must(err1, inc)
+		},
+		want: "start op; must op: 2; defer 2; defer error op: 3; finish op: 2",
+	}, {
+		desc: "discarded",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 }, Discard)
+			e.Must(err1, Discard)
+		},
+		want: "start op; defer 2; finish <nil>",
+	}, {
+		desc: "nested",
+		f: func(e *E) {
+			e.Run(func(e *E) { e.Must(err1) })
+		},
+		want: "start op; must op: 1; finish op: 1",
+	}, {
+		desc: "panic",
+		f: func(e *E) {
+			e.Defer(func() {})
+			panic(err3)
+		},
+		want: "start op; panic 3; defer <nil>; finish 3",
+	}, {
+		desc: "nested panic",
+		f: func(e *E) {
+			e.Defer(func() {})
+			e.Run(func(e *E) { panic(err3) })
+		},
+		want: "start op; panic 3; defer <nil>; finish 3",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			events = nil
+			func() {
+				defer func() { recover() }()
+				ec.Run(tc.f)
+			}()
+			if got := strings.Join(events, "; "); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+
+	events = nil
+	TryWith(ec.With(WithHooks(Hooks{
+		OnFinish: func(s State, err error, elapsed time.Duration) { add("second") },
+	})), nil, func(e *E) int { return 1 })
+	if got, want := strings.Join(events, "; "), "start op; finish <nil>; second"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hook gives package errdtest access to the internals of package errd
+// without exposing them in the API of errd.
+package hook
+
+// An Interceptor is notified of calls to the methods of an errd.E. Handlers
+// are passed as a []errd.Handler.
+type Interceptor interface {
+	// Must is called for each call to Must with a non-nil error, before the
+	// error is passed to any handler.
+	Must(err error, handlers interface{})
+
+	// Defer is called for each call to Defer with a non-nil value. The value
+	// is not added to the defers of E. Scoped holds the handlers added by
+	// Handle that are in scope, in the order in which they apply.
+	Defer(x interface{}, handlers, scoped interface{})
+
+	// Check is called for each call to Check with a non-nil error, before
+	// the error is passed to any handler.
+	Check(err error, handlers interface{})
+}
+
+// Intercept returns a copy of the given *errd.Runner that reports calls to
+// the methods of E to i. It is set by package errd.
+var Intercept func(runner interface{}, i Interceptor) interface{}
@@ -0,0 +1,89 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalJSON returns a JSON representation of err and the errors it wraps.
+// It returns null for a nil error. Otherwise, the result is an object with the
+// following members, where empty members are omitted:
+//
+//	message  the error message
+//	type     the Go type of the error, for instance "*fs.PathError"
+//	ops      the labels added by Named and Op
+//	fields   the fields added by With, as an object
+//	site     the location recorded with the CallSites option
+//	panic    the value of a panic that is not an error
+//	wrapped  the errors returned by Unwrap, as an array of such objects
+//
+// The errors wrapped by an error returned by a Redact handler are not
+// reported.
+//
+// The annotations added by package errd, such as ops and fields, are
+// reported as part of the object of the error they annotate.
+func MarshalJSON(err error) ([]byte, error) {
+	if err == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(describe(err))
+}
+
+type jsonError struct {
+	Message string                 `json:"message"`
+	Type    string                 `json:"type"`
+	Ops     []string               `json:"ops,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Site    string                 `json:"site,omitempty"`
+	Panic   string                 `json:"panic,omitempty"`
+	Wrapped []*jsonError           `json:"wrapped,omitempty"`
+}
+
+func describe(err error) *jsonError {
+	j := &jsonError{Message: err.Error()}
+	for done := false; !done; {
+		switch x := err.(type) {
+		case *opError:
+			j.Ops = append(j.Ops, x.ops...)
+			for _, f := range x.fields {
+				if j.Fields == nil {
+					j.Fields = map[string]interface{}{}
+				}
+				j.Fields[f.Key] = f.Value
+			}
+			err = x.err
+		case *callSiteError:
+			if j.Site == "" {
+				j.Site = x.site
+			}
+			err = x.err
+		default:
+			done = true
+		}
+	}
+	j.Type = fmt.Sprintf("%T", err)
+	switch x := err.(type) {
+	case *panicError:
+		j.Panic = fmt.Sprint(x.value)
+	case *redactedError:
+		// Do not reveal the original message.
+		return j
+	}
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		if w := x.Unwrap(); w != nil {
+			j.Wrapped = []*jsonError{describe(w)}
+		}
+	case interface{ Unwrap() []error }:
+		for _, w := range x.Unwrap() {
+			if w != nil {
+				j.Wrapped = append(j.Wrapped, describe(w))
+			}
+		}
+	}
+	return j
+}
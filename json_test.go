@@ -0,0 +1,87 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	errFoo := errors.New("foo")
+	errBar := errors.New("bar")
+	testCases := []struct {
+		desc string
+		err  func() error
+		want string
+	}{{
+		desc: "nil",
+		err:  func() error { return nil },
+		want: `null`,
+	}, {
+		desc: "simple",
+		err:  func() error { return errFoo },
+		want: `{"message":"foo","type":"*errors.errorString"}`,
+	}, {
+		desc: "wrapped",
+		err: func() error {
+			return fmt.Errorf("x: %w", &fs.PathError{Op: "open", Path: "p", Err: errFoo})
+		},
+		want: `{"message":"x: open p: foo","type":"*fmt.wrapError","wrapped":[` +
+			`{"message":"open p: foo","type":"*fs.PathError","wrapped":[` +
+			`{"message":"foo","type":"*errors.errorString"}]}]}`,
+	}, {
+		desc: "joined",
+		err: func() error {
+			return Run(func(e *E) {
+				e.Check(errFoo)
+				e.Must(errBar)
+			})
+		},
+		want: `{"message":"foo\nbar","type":"*errors.joinError","wrapped":[` +
+			`{"message":"foo","type":"*errors.errorString"},` +
+			`{"message":"bar","type":"*errors.errorString"}]}`,
+	}, {
+		desc: "annotated",
+		err: func() error {
+			return WithDefault().With(Named("op"), CallSites).Run(func(e *E) {
+				e.With("id", 3)
+				e.Op("load")
+				e.Must(errFoo)
+			})
+		},
+		want: `{"message":"op: load: foo","type":"*errors.errorString",` +
+			`"ops":["op","load"],"fields":{"id":3},"site":"SITE"}`,
+	}, {
+		desc: "panic",
+		err: func() (err error) {
+			defer func() { recover() }()
+			Run(func(e *E) {
+				e.Defer(func(s State) error {
+					err = s.Err()
+					return nil
+				})
+				panic("boom")
+			})
+			return err
+		},
+		want: `{"message":"errd: paniced: boom","type":"*errd.panicError","panic":"boom"}`,
+	}}
+	site := regexp.MustCompile(`"site":"[^"]*json_test.go:\d+"`)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			b, err := MarshalJSON(tc.err())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := site.ReplaceAllString(string(b), `"site":"SITE"`); got != tc.want {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
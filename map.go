@@ -0,0 +1,66 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import "errors"
+
+// A Rule maps errors that match a condition to a domain error. Rules are
+// passed to Map.
+type Rule struct {
+	match func(err error) bool
+	to    error
+}
+
+// Is returns a Rule that maps errors for which errors.Is(err, target) holds
+// to the error to.
+func Is(target, to error) Rule {
+	return Rule{func(err error) bool { return errors.Is(err, target) }, to}
+}
+
+// As returns a Rule that maps errors for which errors.As finds an error of
+// type T to the error to.
+func As[T error](to error) Rule {
+	return Rule{func(err error) bool {
+		var x T
+		return errors.As(err, &x)
+	}, to}
+}
+
+// When returns a Rule that maps errors for which match returns true to the
+// error to.
+func When(match func(err error) bool, to error) Rule {
+	return Rule{match, to}
+}
+
+// Map returns a Handler that replaces an error with the domain error of the
+// first matching rule. The replacement wraps both the domain error and the
+// original error, so that errors.Is and errors.As match either of them. Errors
+// that match none of the rules are passed on unchanged.
+//
+// A rule table is typically set up once as a default handler of a Runner:
+//
+//	var ec = errd.WithDefault(errd.Map(
+//		errd.Is(os.ErrNotExist, ErrNotFound),
+//		errd.As[*net.OpError](ErrUnavailable),
+//	))
+func Map(rules ...Rule) Handler {
+	rules = append([]Rule(nil), rules...)
+	return HandlerFunc(func(s State, err error) error {
+		for _, r := range rules {
+			if r.match(err) {
+				return &mappedError{r.to, err}
+			}
+		}
+		return err
+	})
+}
+
+// A mappedError is a domain error that was mapped from err.
+type mappedError struct {
+	to, err error
+}
+
+func (m *mappedError) Error() string   { return m.to.Error() + ": " + m.err.Error() }
+func (m *mappedError) Unwrap() []error { return []error{m.to, m.err} }
@@ -0,0 +1,78 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	errNotFound := errors.New("not found")
+	errCorrupt := errors.New("corrupt")
+	errPath := errors.New("bad path")
+	errOther := errors.New("other")
+
+	ec := WithDefault(Map(
+		Is(os.ErrNotExist, errNotFound),
+		When(func(err error) bool { return err == io.ErrUnexpectedEOF }, errCorrupt),
+		As[*fs.PathError](errPath),
+	))
+	pathErr := &fs.PathError{Op: "open", Path: "x", Err: os.ErrNotExist}
+	testCases := []struct {
+		desc string
+		err  error
+		want error
+		msg  string
+	}{{
+		desc: "is",
+		err:  os.ErrNotExist,
+		want: errNotFound,
+		msg:  "not found: file does not exist",
+	}, {
+		desc: "when",
+		err:  io.ErrUnexpectedEOF,
+		want: errCorrupt,
+		msg:  "corrupt: unexpected EOF",
+	}, {
+		desc: "first rule wins",
+		err:  pathErr,
+		want: errNotFound,
+		msg:  "not found: open x: file does not exist",
+	}, {
+		desc: "as",
+		err:  &fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission},
+		want: errPath,
+		msg:  "bad path: open x: permission denied",
+	}, {
+		desc: "no match",
+		err:  errOther,
+		want: errOther,
+		msg:  "other",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ec.Run(func(e *E) { e.Must(tc.err) })
+			if !errors.Is(err, tc.want) {
+				t.Errorf("got %v; want error matching %v", err, tc.want)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Errorf("got %v; want error wrapping %v", err, tc.err)
+			}
+			if got := err.Error(); got != tc.msg {
+				t.Errorf("message: got %q; want %q", got, tc.msg)
+			}
+		})
+	}
+
+	err := ec.Run(func(e *E) { e.Must(pathErr) })
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe != pathErr {
+		t.Errorf("errors.As: got %v; want %v", pe, pathErr)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics collects metrics about calls to errd.Run.
+//
+// A Collector counts runs, failures, panics and errors returned by deferred
+// functions, and measures the time spent in deferred functions. Metrics are
+// kept per operation, as named by errd.Named:
+//
+//	var collector = metrics.New()
+//
+//	var ec = errd.WithDefault().With(errd.Named("storage"), collector.Option())
+//
+//	func init() {
+//		collector.Publish("errd")
+//	}
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mpvl/errd"
+)
+
+// Stats holds the metrics for a single operation.
+type Stats struct {
+	// Runs is the number of calls to Run that completed.
+	Runs int64 `json:"runs"`
+
+	// Failures is the number of calls to Run that returned an error.
+	Failures int64 `json:"failures"`
+
+	// FailuresByKind breaks down Failures by the name of the matching
+	// error classified with Classify or, otherwise, by the type of the
+	// innermost error found by unwrapping the error. For an error wrapping
+	// multiple errors, the last one is unwrapped.
+	FailuresByKind map[string]int64 `json:"failuresByKind,omitempty"`
+
+	// Panics is the number of panics.
+	Panics int64 `json:"panics"`
+
+	// DeferErrors is the number of errors returned by deferred functions that
+	// persisted after handling.
+	DeferErrors int64 `json:"deferErrors"`
+
+	// Cleanups is the number of deferred functions that were called.
+	Cleanups int64 `json:"cleanups"`
+
+	// CleanupTime is the total time spent in deferred functions.
+	CleanupTime time.Duration `json:"cleanupTime"`
+}
+
+// A Collector collects metrics about calls to Run. It is safe for concurrent
+// use.
+type Collector struct {
+	mu       sync.Mutex
+	ops      map[string]*Stats
+	sentinel []sentinel
+}
+
+type sentinel struct {
+	name string
+	err  error
+}
+
+// New returns a new Collector.
+func New() *Collector {
+	return &Collector{ops: map[string]*Stats{}}
+}
+
+// Classify causes failures that match err, as reported by errors.Is, to be
+// counted under the given name in FailuresByKind. Errors are matched against
+// classified errors in the order in which they were added.
+func (c *Collector) Classify(name string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sentinel = append(c.sentinel, sentinel{name, err})
+}
+
+// Option returns the errd.Option that enables collecting metrics for a
+// Runner.
+func (c *Collector) Option() errd.Option {
+	return errd.WithHooks(c.Hooks())
+}
+
+// Hooks returns the hooks used to collect metrics.
+func (c *Collector) Hooks() errd.Hooks {
+	return errd.Hooks{
+		OnPanic: func(s errd.State, r interface{}) {
+			c.update(s, func(st *Stats) { st.Panics++ })
+		},
+		OnDeferRun: func(s errd.State, elapsed time.Duration, err error) {
+			c.update(s, func(st *Stats) {
+				st.Cleanups++
+				st.CleanupTime += elapsed
+			})
+		},
+		OnDeferError: func(s errd.State, err error) {
+			c.update(s, func(st *Stats) { st.DeferErrors++ })
+		},
+		OnFinish: func(s errd.State, err error, elapsed time.Duration) {
+			c.update(s, func(st *Stats) {
+				st.Runs++
+				if err == nil {
+					return
+				}
+				st.Failures++
+				if st.FailuresByKind == nil {
+					st.FailuresByKind = map[string]int64{}
+				}
+				st.FailuresByKind[c.kind(err)]++
+			})
+		},
+	}
+}
+
+func (c *Collector) update(s errd.State, f func(st *Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.ops[s.Name()]
+	if st == nil {
+		st = &Stats{}
+		c.ops[s.Name()] = st
+	}
+	f(st)
+}
+
+// kind returns the name under which err is counted. c.mu must be held.
+func (c *Collector) kind(err error) string {
+	for _, s := range c.sentinel {
+		if errors.Is(err, s.err) {
+			return s.name
+		}
+	}
+	for {
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			if u := x.Unwrap(); u != nil {
+				err = u
+				continue
+			}
+		case interface{ Unwrap() []error }:
+			// Use the last error, which is the error that caused Run to
+			// fail for errors combined with soft errors, and the original
+			// error for errors mapped by errd.Map.
+			if u := x.Unwrap(); len(u) > 0 {
+				err = u[len(u)-1]
+				continue
+			}
+		}
+		return fmt.Sprintf("%T", err)
+	}
+}
+
+// Snapshot returns a copy of the current metrics, keyed by operation name.
+// Metrics for Runners without a name are keyed by the empty string.
+func (c *Collector) Snapshot() map[string]Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := make(map[string]Stats, len(c.ops))
+	for name, st := range c.ops {
+		s := *st
+		if st.FailuresByKind != nil {
+			s.FailuresByKind = make(map[string]int64, len(st.FailuresByKind))
+			for k, v := range st.FailuresByKind {
+				s.FailuresByKind[k] = v
+			}
+		}
+		m[name] = s
+	}
+	return m
+}
+
+// Publish publishes the snapshot of c as an expvar variable with the given
+// name. Like expvar.Publish, it panics if the name is already in use.
+func (c *Collector) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return c.Snapshot() }))
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mpvl/errd"
+)
+
+type testError struct{}
+
+func (testError) Error() string { return "test" }
+
+func TestCollector(t *testing.T) {
+	c := New()
+	c.Classify("eof", io.EOF)
+	storage := errd.WithDefault().With(errd.Named("storage"), c.Option())
+	unnamed := errd.WithDefault().With(c.Option())
+
+	storage.Run(func(e *errd.E) {
+		e.Defer(func() {})
+	})
+	storage.Run(func(e *errd.E) {
+		e.Defer(func() error { return errors.New("close") })
+		e.Must(io.EOF)
+	})
+	storage.Run(func(e *errd.E) {
+		e.Must(testError{})
+	})
+	storage.Run(func(e *errd.E) {
+		e.Check(errors.New("soft"))
+		e.Must(testError{})
+	})
+	storage.Run(func(e *errd.E) {
+		e.Must(testError{}, errd.Map(errd.Is(testError{}, errors.New("mapped"))))
+	})
+	func() {
+		defer func() { recover() }()
+		unnamed.Run(func(e *errd.E) { panic("foo") })
+	}()
+
+	snap := c.Snapshot()
+	st := snap["storage"]
+	st.CleanupTime = 0
+	want := Stats{
+		Runs:     5,
+		Failures: 4,
+		FailuresByKind: map[string]int64{
+			"eof":               1,
+			"metrics.testError": 3,
+		},
+		DeferErrors: 1,
+		Cleanups:    2,
+	}
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("storage: got %+v; want %+v", st, want)
+	}
+	want = Stats{
+		Runs:           1,
+		Failures:       1,
+		FailuresByKind: map[string]int64{"*errd.panicError": 1},
+		Panics:         1,
+	}
+	if got := snap[""]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unnamed: got %+v; want %+v", got, want)
+	}
+
+	snap["storage"].FailuresByKind["eof"] = 10
+	if got := c.Snapshot()["storage"].FailuresByKind["eof"]; got != 1 {
+		t.Errorf("snapshot shares state with collector: got %d; want 1", got)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	c := New()
+	c.Publish("errd_metrics_test")
+	errd.WithDefault().With(errd.Named("op"), c.Option()).Run(func(e *errd.E) {})
+
+	var got map[string]Stats
+	if err := json.Unmarshal([]byte(expvar.Get("errd_metrics_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["op"].Runs != 1 {
+		t.Errorf("got %+v; want 1 run for op", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"errors"
+	"strings"
+)
+
+// Op adds a label describing the operation performed by the remainder of the
+// scope. The labels of a scope, preceded by the name of the Runner, are
+// prefixed to errors that cause Run to return or that are recorded by Check:
+//
+//	var ec = errd.WithDefault().With(errd.Named("storage"))
+//
+//	ec.Run(func(e *errd.E) {
+//		e.Op("load config")
+//		f, err := os.Open("/etc/x")
+//		e.Must(err) // storage: load config: open /etc/x: no such file or directory
+//		...
+//	})
+//
+// A label applies to errors reported after the call to Op, including errors
+// returned by functions deferred after it, until the end of the scope or the
+// enclosing DeferScope. The labels of an error can be retrieved with Ops, and
+// by handlers with State.Ops.
+func (e *E) Op(label string) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	e.deferred = append(e.deferred, deferData{opLabel(label), popScope})
+}
+
+// An opLabel records a label passed to Op in the defer stack.
+type opLabel string
+
+// A Field is a key-value pair added to a scope with With.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// With adds a field to the remainder of the scope. The fields of a scope are
+// attached to errors that cause Run to return or that are recorded by Check,
+// without altering the error message. They can be retrieved with Fields, and
+// by handlers with State.Values. As with Op, a field applies until the end of
+// the scope or the enclosing DeferScope.
+func (e *E) With(key string, value interface{}) {
+	if e.debug != nil {
+		e.debug.check()
+	}
+	e.deferred = append(e.deferred, deferData{Field{key, value}, popScope})
+}
+
+// values returns the fields in scope.
+func (e *E) values() []Field {
+	var fields []Field
+	for _, d := range e.deferred {
+		if f, ok := d.x.(Field); ok {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// ops returns the name of the Runner, if any, followed by the labels in scope.
+func (e *E) ops() []string {
+	var ops []string
+	if e.runner.name != "" {
+		ops = append(ops, e.runner.name)
+	}
+	for _, d := range e.deferred {
+		if l, ok := d.x.(opLabel); ok {
+			ops = append(ops, string(l))
+		}
+	}
+	return ops
+}
+
+// withOps annotates err with the labels and fields that apply to it, if any.
+func (e *E) withOps(err error) error {
+	ops := e.ops()
+	fields := e.values()
+	if len(ops) == 0 && len(fields) == 0 {
+		return err
+	}
+	var o *opError
+	if errors.As(err, &o) && hasPrefix(o.ops, ops) && hasKeys(o.fields, fields) {
+		// The error was already annotated, for instance by a nested scope.
+		return err
+	}
+	return &opError{ops, fields, err}
+}
+
+func hasPrefix(s, prefix []string) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i, p := range prefix {
+		if s[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// hasKeys reports whether the keys of prefix are a prefix of those of s.
+func hasKeys(s, prefix []Field) bool {
+	if len(s) < len(prefix) {
+		return false
+	}
+	for i, p := range prefix {
+		if s[i].Key != p.Key {
+			return false
+		}
+	}
+	return true
+}
+
+// An opError is an error annotated with the operations during which it
+// occurred and the fields of its scope.
+type opError struct {
+	ops    []string
+	fields []Field
+	err    error
+}
+
+func (o *opError) Error() string {
+	if len(o.ops) == 0 {
+		return o.err.Error()
+	}
+	return strings.Join(o.ops, ": ") + ": " + o.err.Error()
+}
+
+func (o *opError) Unwrap() error { return o.err }
+
+// Ops returns the labels added to err by Named and Op, outermost first. It
+// includes the labels of errors wrapped by err.
+func Ops(err error) []string {
+	var ops []string
+	for {
+		var o *opError
+		if !errors.As(err, &o) {
+			return ops
+		}
+		ops = append(ops, o.ops...)
+		err = o.err
+	}
+}
+
+// Fields returns the fields attached to err with With, outermost first. It
+// includes the fields of errors wrapped by err.
+func Fields(err error) []Field {
+	var fields []Field
+	for {
+		var o *opError
+		if !errors.As(err, &o) {
+			return fields
+		}
+		fields = append(fields, o.fields...)
+		err = o.err
+	}
+}
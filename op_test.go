@@ -0,0 +1,254 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOp(t *testing.T) {
+	errFoo := errors.New("foo")
+	named := WithDefault().With(Named("storage"))
+	testCases := []struct {
+		desc   string
+		runner *Runner
+		f      func(e *E)
+		want   string
+		ops    []string
+	}{{
+		desc:   "no labels",
+		runner: WithDefault(),
+		f:      func(e *E) { e.Must(errFoo) },
+		want:   "foo",
+	}, {
+		desc:   "named",
+		runner: named,
+		f:      func(e *E) { e.Must(errFoo) },
+		want:   "storage: foo",
+		ops:    []string{"storage"},
+	}, {
+		desc:   "op",
+		runner: named,
+		f: func(e *E) {
+			e.Op("load config")
+			e.Must(errFoo)
+		},
+		want: "storage: load config: foo",
+		ops:  []string{"storage", "load config"},
+	}, {
+		desc:   "after error",
+		runner: WithDefault(),
+		f: func(e *E) {
+			e.Must(errFoo)
+			e.Op("not reached")
+		},
+		want: "foo",
+	}, {
+		desc:   "handlers see unlabeled error",
+		runner: WithDefault(inc),
+		f: func(e *E) {
+			e.Op("a")
+			e.Must(err1)
+		},
+		want: "a: 2",
+		ops:  []string{"a"},
+	}, {
+		desc:   "defer scope",
+		runner: WithDefault(),
+		f: func(e *E) {
+			e.Op("a")
+			e.DeferScope(func() { e.Op("b") })
+			e.Must(errFoo)
+		},
+		want: "a: foo",
+		ops:  []string{"a"},
+	}, {
+		desc:   "defer",
+		runner: WithDefault(),
+		f: func(e *E) {
+			e.Defer(func() error { return err1 })
+			e.Op("a")
+			e.Defer(func() error { return err2 })
+			e.Op("b")
+		},
+		want: "a: 2",
+		ops:  []string{"a"},
+	}, {
+		desc:   "check",
+		runner: WithDefault(),
+		f: func(e *E) {
+			e.Op("a")
+			e.Check(errFoo)
+		},
+		want: "a: foo",
+		ops:  []string{"a"},
+	}, {
+		desc:   "nested",
+		runner: named,
+		f: func(e *E) {
+			e.Op("a")
+			e.Run(func(e *E) {
+				e.Op("b")
+				e.Must(errFoo)
+			})
+		},
+		want: "storage: a: b: foo",
+		ops:  []string{"storage", "a", "b"},
+	}, {
+		desc:   "nested try",
+		runner: named,
+		f: func(e *E) {
+			e.Op("a")
+			e.Must(e.Try(func(e *E) { e.Must(errFoo) }))
+		},
+		want: "storage: a: foo",
+		ops:  []string{"storage", "a"},
+	}, {
+		desc:   "nested try with call sites",
+		runner: named.With(CallSites),
+		f: func(e *E) {
+			e.Op("a")
+			e.Must(e.Try(func(e *E) { e.Must(errFoo) }))
+		},
+		want: "storage: a: foo",
+		ops:  []string{"storage", "a"},
+	}, {
+		desc:   "wrapped",
+		runner: named,
+		f: func(e *E) {
+			e.Op("outer")
+			err := WithDefault().With(Named("inner")).Run(func(e *E) {
+				e.Must(errFoo)
+			})
+			e.Must(fmt.Errorf("wrapped: %w", err))
+		},
+		want: "storage: outer: wrapped: inner: foo",
+		ops:  []string{"storage", "outer", "inner"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.runner.Run(tc.f)
+			if got := fmt.Sprint(err); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+			if got := Ops(err); !reflect.DeepEqual(got, tc.ops) {
+				t.Errorf("ops: got %q; want %q", got, tc.ops)
+			}
+		})
+	}
+
+	err := named.Run(func(e *E) {
+		e.Op("open")
+		_, err := os.Open("/does/not/exist")
+		e.Must(err)
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v; want error matching os.ErrNotExist", err)
+	}
+}
+
+func TestWith(t *testing.T) {
+	errFoo := errors.New("foo")
+	var values []Field
+	keep := HandlerFunc(func(s State, err error) error {
+		values = s.Values()
+		return err
+	})
+	testCases := []struct {
+		desc   string
+		f      func(e *E)
+		want   string
+		fields []Field
+		values []Field
+	}{{
+		desc: "no fields",
+		f:    func(e *E) { e.Must(errFoo, keep) },
+		want: "foo",
+	}, {
+		desc: "fields",
+		f: func(e *E) {
+			e.With("bucket", "b")
+			e.With("id", 3)
+			e.Must(errFoo, keep)
+		},
+		want:   "foo",
+		fields: []Field{{"bucket", "b"}, {"id", 3}},
+		values: []Field{{"bucket", "b"}, {"id", 3}},
+	}, {
+		desc: "with op",
+		f: func(e *E) {
+			e.With("bucket", "b")
+			e.Op("write")
+			e.Must(errFoo, keep)
+		},
+		want:   "write: foo",
+		fields: []Field{{"bucket", "b"}},
+		values: []Field{{"bucket", "b"}},
+	}, {
+		desc: "defer scope",
+		f: func(e *E) {
+			e.With("a", 1)
+			e.DeferScope(func() { e.With("b", 2) })
+			e.Must(errFoo, keep)
+		},
+		want:   "foo",
+		fields: []Field{{"a", 1}},
+		values: []Field{{"a", 1}},
+	}, {
+		desc: "defer",
+		f: func(e *E) {
+			e.With("a", 1)
+			e.Defer(func() error { return errFoo }, keep)
+			e.With("b", 2)
+		},
+		want:   "foo",
+		fields: []Field{{"a", 1}},
+		values: []Field{{"a", 1}},
+	}, {
+		desc: "nested",
+		f: func(e *E) {
+			e.With("a", 1)
+			e.Must(e.Try(func(e *E) {
+				e.With("b", 2)
+				e.Must(errFoo, keep)
+			}))
+		},
+		want:   "foo",
+		fields: []Field{{"a", 1}, {"b", 2}},
+		values: []Field{{"a", 1}, {"b", 2}},
+	}, {
+		desc: "wrapped",
+		f: func(e *E) {
+			e.With("a", 1)
+			err := Run(func(e *E) {
+				e.With("b", 2)
+				e.Must(errFoo)
+			})
+			e.Must(fmt.Errorf("wrapped: %w", err), keep)
+		},
+		want:   "wrapped: foo",
+		fields: []Field{{"a", 1}, {"b", 2}},
+		values: []Field{{"a", 1}},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			values = nil
+			err := Run(tc.f)
+			if got := fmt.Sprint(err); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+			if got := Fields(err); !reflect.DeepEqual(got, tc.fields) {
+				t.Errorf("fields: got %v; want %v", got, tc.fields)
+			}
+			if !reflect.DeepEqual(values, tc.values) {
+				t.Errorf("values: got %v; want %v", values, tc.values)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import "regexp"
+
+const redacted = "REDACTED"
+
+var (
+	// urlUserinfo matches the scheme and userinfo of a URL.
+	urlUserinfo = regexp.MustCompile(`\b([a-zA-Z][a-zA-Z0-9+.-]*://)[^/?#@\s]+@`)
+
+	// keyValue matches key=value pairs with a key that suggests a secret,
+	// including keys like access_token and client_secret. An unquoted value
+	// does not end with a colon, as it typically separates the parts of an
+	// error message.
+	keyValue = regexp.MustCompile(`(?i)\b([\w-]*?(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|auth)\s*=\s*)("[^"]*"|'[^']*'|[^\s&;,]*[^\s&;,:])`)
+)
+
+// Redact returns a Handler that removes secrets from error messages. It
+// strips the userinfo from URLs, replaces the values of key=value pairs with
+// keys like password, secret, and token, and replaces matches of the given
+// patterns with REDACTED.
+//
+// The resulting error wraps the original one, so that errors.Is and errors.As
+// still match it. Note that the message of the original error, and of errors
+// it wraps, is therefore still accessible to code that unwraps it.
+// MarshalJSON does not report errors wrapped by a redacted error.
+func Redact(patterns ...*regexp.Regexp) Handler {
+	patterns = append([]*regexp.Regexp(nil), patterns...)
+	return HandlerFunc(func(s State, err error) error {
+		return redact(err, patterns)
+	})
+}
+
+// Redacting returns an Option that applies Redact(patterns...) to all errors
+// before passing them to any handler. This prevents handlers that log errors
+// from leaking secrets.
+func Redacting(patterns ...*regexp.Regexp) Option {
+	patterns = append([]*regexp.Regexp(nil), patterns...)
+	return func(c *config) {
+		c.redact = func(err error) error { return redact(err, patterns) }
+	}
+}
+
+func redact(err error, patterns []*regexp.Regexp) error {
+	msg := err.Error()
+	s := urlUserinfo.ReplaceAllString(msg, "$1")
+	s = keyValue.ReplaceAllString(s, "${1}"+redacted)
+	for _, p := range patterns {
+		s = p.ReplaceAllLiteralString(s, redacted)
+	}
+	if s == msg {
+		return err
+	}
+	return &redactedError{s, err}
+}
+
+// A redactedError is an error with a redacted message.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (r *redactedError) Error() string { return r.msg }
+func (r *redactedError) Unwrap() error { return r.err }
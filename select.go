@@ -0,0 +1,55 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+// A Selector chooses the error to be returned by Run if an error is
+// encountered after another one was already recorded. It returns the error to
+// keep, which is typically one of current or next. A Selector is not applied
+// while panicking; the error resulting from the panic is kept.
+type Selector func(current, next error) error
+
+var (
+	// FirstWins is a Selector that keeps the first error encountered. This
+	// is the default.
+	FirstWins Selector = func(current, next error) error { return current }
+
+	// LastWins is a Selector that keeps the last error encountered.
+	LastWins Selector = func(current, next error) error { return next }
+)
+
+// BySeverity returns a Selector that replaces the current error with the next
+// one if it has a higher rank. For example, a ranking that assigns a low rank
+// to io.EOF allows an error from closing a writer to take precedence over an
+// earlier io.EOF.
+func BySeverity(rank func(err error) int) Selector {
+	return func(current, next error) error {
+		if rank(next) > rank(current) {
+			return next
+		}
+		return current
+	}
+}
+
+// Select returns an Option that sets the Selector used to choose between
+// errors. Handlers can override the choice using State.Replace.
+func Select(s Selector) Option {
+	return func(c *config) { c.selector = s }
+}
+
+// setErr records err as the error to be returned by Run, subject to the
+// Selector of the Runner.
+func (e *E) setErr(err error) {
+	switch {
+	case e.err == nil:
+	case e.runner.selector == nil, e.runner.inPanic:
+		// The error of a panic is always kept.
+		return
+	default:
+		if err = e.runner.selector(*e.err, err); err == nil {
+			return
+		}
+	}
+	e.err = &err
+}
@@ -0,0 +1,142 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	errClose := errors.New("close failed")
+	rank := func(err error) int {
+		if err == io.EOF {
+			return 0
+		}
+		return 1
+	}
+	replace := func(err error) Handler {
+		return HandlerFunc(func(s State, e error) error {
+			s.Replace(err)
+			return e
+		})
+	}
+	testCases := []struct {
+		desc string
+		opts []Option
+		f    func(e *E)
+		want error
+	}{{
+		desc: "default",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 })
+			e.Defer(func() error { return err1 })
+		},
+		want: err1,
+	}, {
+		desc: "first wins",
+		opts: []Option{Select(FirstWins)},
+		f: func(e *E) {
+			e.Defer(func() error { return err2 })
+			e.Must(err1)
+		},
+		want: err1,
+	}, {
+		desc: "last wins",
+		opts: []Option{Select(LastWins)},
+		f: func(e *E) {
+			e.Defer(func() error { return err3 })
+			e.Defer(func() error { return err2 })
+			e.Must(err1)
+		},
+		want: err3,
+	}, {
+		desc: "severity replaces benign error",
+		opts: []Option{Select(BySeverity(rank))},
+		f: func(e *E) {
+			e.Defer(func() error { return errClose })
+			e.Must(io.EOF)
+		},
+		want: errClose,
+	}, {
+		desc: "severity keeps equal rank",
+		opts: []Option{Select(BySeverity(rank))},
+		f: func(e *E) {
+			e.Defer(func() error { return errClose })
+			e.Must(err1)
+		},
+		want: err1,
+	}, {
+		desc: "severity keeps higher rank",
+		opts: []Option{Select(BySeverity(rank))},
+		f: func(e *E) {
+			e.Defer(func() error { return io.EOF })
+			e.Must(err1)
+		},
+		want: err1,
+	}, {
+		desc: "replace",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 }, replace(err3))
+			e.Must(err1)
+		},
+		want: err3,
+	}, {
+		desc: "replace nil",
+		f: func(e *E) {
+			e.Defer(func() error { return err2 }, replace(nil))
+			e.Must(err1)
+		},
+		want: err1,
+	}, {
+		desc: "replace last wins",
+		opts: []Option{Select(LastWins)},
+		f: func(e *E) {
+			e.Must(err1, replace(err2))
+		},
+		want: err2,
+	}, {
+		desc: "replace by severity",
+		opts: []Option{Select(BySeverity(rank))},
+		f: func(e *E) {
+			e.Defer(func() error { return errClose }, replace(io.EOF))
+			e.Must(err1)
+		},
+		want: io.EOF,
+	}, {
+		desc: "replace then discard",
+		f: func(e *E) {
+			e.Must(err1, replace(err2), Discard)
+		},
+		want: err2,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := WithDefault().With(tc.opts...).Run(tc.f)
+			if err != tc.want {
+				t.Errorf("got %v; want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectPanic(t *testing.T) {
+	var got error
+	func() {
+		defer func() { recover() }()
+		WithDefault().With(Select(LastWins)).Run(func(e *E) {
+			e.Defer(func() error { return err3 }, HandlerFunc(func(s State, err error) error {
+				got = s.Err()
+				return err
+			}))
+			e.Defer(func() error { return err2 })
+			panic(err1)
+		})
+	}()
+	if got != err1 {
+		t.Errorf("got %v; want %v", got, err1)
+	}
+}
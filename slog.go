@@ -0,0 +1,106 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx that carries l. Handlers created by
+// Logger log to l for scopes that use the returned context.
+func ContextWithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or nil if there is
+// none.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	l, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	return l
+}
+
+// Logger returns a Handler that logs the errors passed to it at level Error
+// and passes them on unchanged. It logs to the logger carried by the context
+// of the scope, if any, or to l otherwise. If l is nil, slog.Default() is used.
+//
+// Each record has the error message and the following attributes:
+//   - op: the name of the Runner, if set with Named, followed by the labels
+//     added with Op that are in scope, separated by ": ",
+//   - site: the location of the call that reported the error,
+//   - source: "must", "defer", or "panic", depending on whether the error
+//     was passed to Must or a similar method, returned by a deferred
+//     function, or resulted from a panic; a panic is passed to the handlers
+//     of the top-level scope,
+//   - the fields added with With that are in scope,
+//   - for each of the given keys for which the context holds a value, an
+//     attribute named fmt.Sprint(key) with that value.
+//
+// The context is also passed to the slog.Handler of the logger.
+func Logger(l *slog.Logger, keys ...interface{}) Handler {
+	keys = append([]interface{}(nil), keys...)
+	return HandlerFunc(func(s State, err error) error {
+		ctx := s.Context()
+		r := &logRecord{logger: l, ctx: ctx}
+		if x := LoggerFromContext(ctx); x != nil {
+			r.logger = x
+		}
+		if r.logger == nil {
+			r.logger = slog.Default()
+		}
+		if ops := s.Ops(); len(ops) > 0 {
+			r.attrs = append(r.attrs, slog.String("op", strings.Join(ops, ": ")))
+		}
+		source := "must"
+		switch {
+		case s.InDefer():
+			source = "defer"
+		case s.Panicking():
+			source = "panic"
+		}
+		r.attrs = append(r.attrs,
+			slog.String("site", callerSite()),
+			slog.String("source", source))
+		for _, f := range s.Values() {
+			r.attrs = append(r.attrs, slog.Any(f.Key, f.Value))
+		}
+		for _, k := range keys {
+			if v := ctx.Value(k); v != nil {
+				r.attrs = append(r.attrs, slog.Any(fmt.Sprint(k), v))
+			}
+		}
+		if st, ok := s.(*state); ok && st.runner.logOnce {
+			// Attach the record to the top-level scope, as an error of a
+			// nested scope may not be returned by Run.
+			if top := (*E)(st).root(); top.logged == nil {
+				top.logged = r
+			}
+			return err
+		}
+		r.log(err)
+		return err
+	})
+}
+
+// LogOnce is an Option that causes handlers created by Logger to log at most
+// once per call to Run, when it returns, instead of each time they are
+// invoked. The record has the error returned by Run and the attributes
+// determined by the first invocation. Nothing is logged if Run returns nil.
+var LogOnce Option = func(c *config) { c.logOnce = true }
+
+// A logRecord holds the data for logging an error.
+type logRecord struct {
+	logger *slog.Logger
+	ctx    context.Context
+	attrs  []slog.Attr
+}
+
+func (r *logRecord) log(err error) {
+	r.logger.LogAttrs(r.ctx, slog.LevelError, err.Error(), r.attrs...)
+}
@@ -0,0 +1,183 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type ctxKey string
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestLogger(t *testing.T) {
+	errFoo := errors.New("foo")
+	var buf bytes.Buffer
+	l := newTestLogger(&buf)
+	site := regexp.MustCompile(` site=\S+`)
+
+	var line int
+	testCases := []struct {
+		desc   string
+		runner *Runner
+		f      func(e *E)
+		want   string
+	}{{
+		desc:   "must",
+		runner: WithDefault(Logger(l)),
+		f: func(e *E) {
+			_, _, line, _ = runtime.Caller(0)
+			e.Must(errFoo)
+		},
+		want: "level=ERROR msg=foo site source=must\n",
+	}, {
+		desc:   "op",
+		runner: WithDefault(Logger(l)).With(Named("storage")),
+		f:      func(e *E) { e.Must(errFoo) },
+		want:   "level=ERROR msg=foo op=storage site source=must\n",
+	}, {
+		desc:   "op labels",
+		runner: WithDefault(Logger(l)).With(Named("storage")),
+		f: func(e *E) {
+			e.Op("load")
+			e.Must(errFoo)
+		},
+		want: "level=ERROR msg=foo op=\"storage: load\" site source=must\n",
+	}, {
+		desc:   "fields",
+		runner: WithDefault(Logger(l)),
+		f: func(e *E) {
+			e.With("bucket", "b")
+			e.Must(errFoo)
+		},
+		want: "level=ERROR msg=foo site source=must bucket=b\n",
+	}, {
+		desc:   "defer",
+		runner: WithDefault(Logger(l)),
+		f: func(e *E) {
+			e.Defer(func() error { return errFoo })
+			e.Must(err1)
+		},
+		want: "level=ERROR msg=1 site source=must\n" +
+			"level=ERROR msg=foo site source=defer\n",
+	}, {
+		desc:   "panic",
+		runner: WithDefault(Logger(l)),
+		f: func(e *E) {
+			e.Defer(func() error { return errFoo })
+			_, _, line, _ = runtime.Caller(0)
+			panic(err2)
+		},
+		want: "level=ERROR msg=2 site source=panic\n" +
+			"level=ERROR msg=foo site source=defer\n",
+	}, {
+		desc:   "nested panic",
+		runner: WithDefault(Logger(l)),
+		f: func(e *E) {
+			e.Run(func(e *E) { panic("bar") })
+		},
+		want: "level=ERROR msg=\"errd: paniced: bar\" site source=panic\n",
+	}, {
+		desc:   "discarded",
+		runner: WithDefault(Logger(l), Discard),
+		f:      func(e *E) { e.Must(errFoo) },
+		want:   "level=ERROR msg=foo site source=must\n",
+	}, {
+		desc:   "once",
+		runner: WithDefault(Logger(l), inc).With(LogOnce),
+		f: func(e *E) {
+			e.Defer(func() error { return err3 })
+			e.Must(err1)
+		},
+		want: "level=ERROR msg=2 site source=must\n",
+	}, {
+		desc:   "once discarded",
+		runner: WithDefault(Logger(l), Discard).With(LogOnce),
+		f:      func(e *E) { e.Must(errFoo) },
+		want:   "",
+	}, {
+		desc:   "once panic",
+		runner: WithDefault(Logger(l)).With(LogOnce),
+		f: func(e *E) {
+			e.Defer(func() error { return errFoo })
+			panic(err2)
+		},
+		want: "level=ERROR msg=2 site source=panic\n",
+	}, {
+		desc:   "once nested",
+		runner: WithDefault(Logger(l)).With(LogOnce),
+		f: func(e *E) {
+			e.Try(func(e *E) { e.Must(errFoo) })
+		},
+		want: "",
+	}, {
+		desc:   "once nested failure",
+		runner: WithDefault(Logger(l)).With(LogOnce),
+		f: func(e *E) {
+			e.Run(func(e *E) { e.Must(errFoo) })
+		},
+		want: "level=ERROR msg=foo site source=must\n",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			buf.Reset()
+			func() {
+				defer func() { recover() }()
+				tc.runner.Run(tc.f)
+			}()
+			got := buf.String()
+			if strings.Contains(got, "runtime/") {
+				t.Errorf("got %q; want site outside of the runtime", got)
+			}
+			if line > 0 {
+				want := fmt.Sprintf("slog_test.go:%d", line+1)
+				if !strings.Contains(got, want) {
+					t.Errorf("got %q; want site %q", got, want)
+				}
+				line = 0
+			}
+			if got = site.ReplaceAllString(got, " site"); got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	var def, buf bytes.Buffer
+	ec := WithDefault(Logger(newTestLogger(&def), ctxKey("request"), ctxKey("missing")))
+
+	ctx := context.WithValue(context.Background(), ctxKey("request"), 42)
+	ec.RunWithContext(ctx, func(e *E) { e.Must(err1) })
+	if got, want := def.String(), "request=42\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %q; want suffix %q", got, want)
+	}
+
+	ctx = ContextWithLogger(ctx, newTestLogger(&buf))
+	ec.RunWithContext(ctx, func(e *E) { e.Must(err2) })
+	if got, want := buf.String(), "msg=2"; !strings.Contains(got, want) {
+		t.Errorf("got %q; want message logged to context logger", got)
+	}
+	if strings.Contains(def.String(), "msg=2") {
+		t.Errorf("error logged to default logger")
+	}
+}
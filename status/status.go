@@ -0,0 +1,129 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package status attaches HTTP status codes to errors.
+//
+// Errors with a code implement the StatusCode method used by package errdhttp
+// to determine the status of a response. Codes can be attached directly, with
+// constructors like NotFound, or by handlers passing through errd:
+//
+//	var ec = errd.WithDefault(status.Classify)
+//
+//	ec.Run(func(e *errd.E) {
+//		id, err := strconv.Atoi(r.FormValue("id"))
+//		e.Must(err, status.As(http.StatusBadRequest))
+//		...
+//	})
+//
+// Codes are attached by wrapping errors, so they survive further wrapping,
+// for instance by other handlers.
+package status
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/mpvl/errd"
+)
+
+// A Code is an HTTP status code.
+type Code int
+
+// String returns the status text of c.
+func (c Code) String() string {
+	return http.StatusText(int(c))
+}
+
+// New returns an error wrapping err with the given code. It returns nil if
+// err is nil.
+func New(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codeError{code, err}
+}
+
+// BadRequest attaches http.StatusBadRequest to err.
+func BadRequest(err error) error { return New(http.StatusBadRequest, err) }
+
+// Unauthorized attaches http.StatusUnauthorized to err.
+func Unauthorized(err error) error { return New(http.StatusUnauthorized, err) }
+
+// Forbidden attaches http.StatusForbidden to err.
+func Forbidden(err error) error { return New(http.StatusForbidden, err) }
+
+// NotFound attaches http.StatusNotFound to err.
+func NotFound(err error) error { return New(http.StatusNotFound, err) }
+
+// Conflict attaches http.StatusConflict to err.
+func Conflict(err error) error { return New(http.StatusConflict, err) }
+
+// Internal attaches http.StatusInternalServerError to err.
+func Internal(err error) error { return New(http.StatusInternalServerError, err) }
+
+// Unavailable attaches http.StatusServiceUnavailable to err.
+func Unavailable(err error) error { return New(http.StatusServiceUnavailable, err) }
+
+// GatewayTimeout attaches http.StatusGatewayTimeout to err.
+func GatewayTimeout(err error) error { return New(http.StatusGatewayTimeout, err) }
+
+type codeError struct {
+	code Code
+	err  error
+}
+
+func (e *codeError) Error() string   { return e.err.Error() }
+func (e *codeError) Unwrap() error   { return e.err }
+func (e *codeError) StatusCode() int { return int(e.code) }
+
+// Of returns the code attached to err, or 0 if it has none. The outermost
+// code takes precedence.
+func Of(err error) Code {
+	var c *codeError
+	if errors.As(err, &c) {
+		return c.code
+	}
+	return 0
+}
+
+// As returns a Handler that attaches code to the errors passed to it. The code
+// takes precedence over codes attached earlier.
+func As(code Code) errd.Handler {
+	return errd.HandlerFunc(func(s errd.State, err error) error {
+		return New(code, err)
+	})
+}
+
+// Classify is a Handler that attaches a code to errors that have none, based
+// on the following errors:
+//
+//	os.ErrNotExist            http.StatusNotFound
+//	os.ErrPermission          http.StatusForbidden
+//	context.DeadlineExceeded  http.StatusGatewayTimeout
+//
+// It is typically used as a default handler.
+var Classify errd.Handler = errd.HandlerFunc(classify)
+
+var classes = []struct {
+	err  error
+	code Code
+}{
+	{os.ErrNotExist, http.StatusNotFound},
+	{os.ErrPermission, http.StatusForbidden},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+}
+
+func classify(s errd.State, err error) error {
+	if Of(err) != 0 {
+		return err
+	}
+	for _, c := range classes {
+		if errors.Is(err, c.err) {
+			return New(c.code, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/mpvl/errd"
+)
+
+func TestStatus(t *testing.T) {
+	errFoo := errors.New("foo")
+	errDomain := errors.New("domain")
+	ec := errd.WithDefault(Classify).With(errd.Named("op"))
+	msg := errd.HandlerFunc(func(s errd.State, err error) error {
+		return fmt.Errorf("msg: %w", err)
+	})
+
+	testCases := []struct {
+		desc string
+		f    func(e *errd.E)
+		code Code
+		msg  string
+	}{{
+		desc: "none",
+		f:    func(e *errd.E) { e.Must(errFoo) },
+		code: 0,
+		msg:  "op: foo",
+	}, {
+		desc: "constructor",
+		f:    func(e *errd.E) { e.Must(NotFound(errFoo)) },
+		code: http.StatusNotFound,
+		msg:  "op: foo",
+	}, {
+		desc: "handler",
+		f:    func(e *errd.E) { e.Must(errFoo, As(http.StatusBadRequest)) },
+		code: http.StatusBadRequest,
+		msg:  "op: foo",
+	}, {
+		desc: "handler overrides",
+		f:    func(e *errd.E) { e.Must(NotFound(errFoo), As(http.StatusConflict)) },
+		code: http.StatusConflict,
+	}, {
+		desc: "survives wrapping",
+		f: func(e *errd.E) {
+			e.Op("load")
+			e.Must(errFoo, As(http.StatusBadRequest), msg, errd.Map(errd.Is(errFoo, errDomain)))
+		},
+		code: http.StatusBadRequest,
+		msg:  "op: load: domain: msg: foo",
+	}, {
+		desc: "classify not exist",
+		f: func(e *errd.E) {
+			_, err := os.Open("/does/not/exist")
+			e.Must(err)
+		},
+		code: http.StatusNotFound,
+	}, {
+		desc: "classify permission",
+		f:    func(e *errd.E) { e.Must(fmt.Errorf("x: %w", os.ErrPermission)) },
+		code: http.StatusForbidden,
+	}, {
+		desc: "classify deadline",
+		f: func(e *errd.E) {
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			<-ctx.Done()
+			e.Must(ctx.Err())
+		},
+		code: http.StatusGatewayTimeout,
+	}, {
+		desc: "classify keeps code",
+		f:    func(e *errd.E) { e.Must(Unavailable(os.ErrNotExist)) },
+		code: http.StatusServiceUnavailable,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ec.Run(tc.f)
+			if got := Of(err); got != tc.code {
+				t.Errorf("code: got %d; want %d", got, tc.code)
+			}
+			var sc interface{ StatusCode() int }
+			if tc.code != 0 && (!errors.As(err, &sc) || sc.StatusCode() != int(tc.code)) {
+				t.Errorf("StatusCode: got %v; want %d", sc, tc.code)
+			}
+			if tc.msg != "" && err.Error() != tc.msg {
+				t.Errorf("message: got %q; want %q", err, tc.msg)
+			}
+		})
+	}
+
+	if New(http.StatusNotFound, nil) != nil {
+		t.Error("New with nil error returned non-nil")
+	}
+	if got, want := Code(http.StatusNotFound).String(), "Not Found"; got != want {
+		t.Errorf("String: got %q; want %q", got, want)
+	}
+}
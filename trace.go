@@ -0,0 +1,84 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"context"
+	"time"
+)
+
+// A Tracer creates spans for calls to Run. Adapters to tracing systems
+// implement Tracer and Span. Timestamps are passed explicitly, so that spans
+// can be recorded after the fact.
+type Tracer interface {
+	// Start starts a span with the given name at the given time. The
+	// returned context is used for the scope.
+	Start(ctx context.Context, name string, start time.Time) (context.Context, Span)
+}
+
+// A Span represents a call to Run or to a deferred function.
+type Span interface {
+	// Child starts a child span with the given name.
+	Child(name string, start time.Time) Span
+
+	// Event records an event, such as an error passed to Must.
+	Event(name string, t time.Time, err error)
+
+	// End ends the span with the given error.
+	End(t time.Time, err error)
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span of the Run that uses ctx, or nil if there
+// is none. A Tracer can use it to find the parent span of a call to Run.
+func SpanFromContext(ctx context.Context) Span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanKey{}).(Span)
+	return s
+}
+
+// Trace returns an Option that creates a span with t for each call to Run. The
+// span is named after the Runner, as set with Named, or "errd.Run" otherwise.
+// It has a child span named "defer" for each deferred function that is called
+// and an event named "must" or "panic" for each error passed to Must or a
+// similar method that causes Run to return, or for a panic.
+func Trace(t Tracer) Option {
+	span := func(s State) Span { return SpanFromContext(s.Context()) }
+	return WithHooks(Hooks{
+		OnStart: func(s State) context.Context {
+			name := s.Name()
+			if name == "" {
+				name = "errd.Run"
+			}
+			ctx, sp := t.Start(s.Context(), name, time.Now())
+			return context.WithValue(ctx, spanKey{}, sp)
+		},
+		OnMustFailure: func(s State, err error) {
+			if sp := span(s); sp != nil {
+				sp.Event("must", time.Now(), err)
+			}
+		},
+		OnDeferRun: func(s State, elapsed time.Duration, err error) {
+			if sp := span(s); sp != nil {
+				end := time.Now()
+				sp.Child("defer", end.Add(-elapsed)).End(end, err)
+			}
+		},
+		OnPanic: func(s State, r interface{}) {
+			if sp := span(s); sp != nil {
+				err, _ := r.(error)
+				sp.Event("panic", time.Now(), err)
+			}
+		},
+		OnFinish: func(s State, err error, elapsed time.Duration) {
+			if sp := span(s); sp != nil {
+				sp.End(time.Now(), err)
+			}
+		},
+	})
+}
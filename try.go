@@ -0,0 +1,43 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import "context"
+
+// Try calls TryWith(Default, nil, f).
+func Try[T any](f func(e *E) T) (T, error) {
+	return TryWith(Default, nil, f)
+}
+
+// TryWithContext calls TryWith(Default, ctxt, f).
+func TryWithContext[T any](ctxt context.Context, f func(e *E) T) (T, error) {
+	return TryWith(Default, ctxt, f)
+}
+
+// TryWith is like r.RunWithContext, but returns the value returned by f. It
+// returns the zero value of T if an error is encountered, including errors in
+// defers that occur after f returns. A nil ctxt is equivalent to using Run.
+//
+// A call to e.Return within f also results in the zero value, but with a nil
+// error, as f does not return a value.
+func TryWith[T any](r *Runner, ctxt context.Context, f func(e *E) T) (T, error) {
+	v, err := try(r, ctxt, f)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+func try[T any](r *Runner, ctxt context.Context, f func(e *E) T) (v T, err error) {
+	var e E
+	e.init(r.config, ctxt)
+	if len(r.hooks) > 0 {
+		e.startHooks()
+	}
+	defer doRecover(&e, &err)
+	v = f(&e)
+	return v, e.finish()
+}
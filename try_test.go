@@ -0,0 +1,63 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errd
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTry(t *testing.T) {
+	errFoo := errors.New("foo")
+
+	v, err := Try(func(e *E) string {
+		e.Must(nil)
+		return "bar"
+	})
+	if v != "bar" || err != nil {
+		t.Errorf("got %q, %v; want %q, nil", v, err, "bar")
+	}
+
+	v, err = Try(func(e *E) string {
+		e.Must(errFoo)
+		return "bar"
+	})
+	if v != "" || err != errFoo {
+		t.Errorf("got %q, %v; want %q, %v", v, err, "", errFoo)
+	}
+
+	// An error in a defer is detected after f returns.
+	v, err = Try(func(e *E) string {
+		e.Defer(func() error { return errFoo })
+		return "bar"
+	})
+	if v != "" || err != errFoo {
+		t.Errorf("got %q, %v; want %q, %v", v, err, "", errFoo)
+	}
+
+	// Default handlers apply.
+	n, err := TryWith(WithDefault(inc), nil, func(e *E) int {
+		e.Must(err1)
+		return 1
+	})
+	if n != 0 || err != err2 {
+		t.Errorf("got %v, %v; want 0, %v", n, err, err2)
+	}
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	var got interface{}
+	TryWithContext(ctx, func(e *E) int {
+		e.Defer(func(s State) error {
+			got = s.Context().Value(key{})
+			return nil
+		})
+		return 0
+	})
+	if got != "value" {
+		t.Errorf("context value: got %v; want %q", got, "value")
+	}
+}